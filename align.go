@@ -0,0 +1,102 @@
+package pixmatch
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+)
+
+// alignMinSize is the minimum size of the downscaled plane used to estimate
+// the alignment.
+const alignMinSize = 16
+
+// Align estimates the global translation of the second image within the
+// search radius. Returns the offset, so that the pixel (x, y) of the image
+// matches the pixel (x+dx, y+dy) of the second image.
+//
+// The offset is searched from the coarse to the fine scale: the full search
+// is done on the downscaled luminance planes, and refined on every larger
+// scale.
+func (img *Image) Align(img2 *Image, radius int) (image.Point, error) {
+	if img.Empty() || img2.Empty() {
+		return image.Point{}, ErrImageIsEmpty
+	}
+	if !img.DimensionsEqual(img2) {
+		return image.Point{}, ErrDimensionsDoNotMatch
+	}
+
+	p1, p2 := []*plane{newLumaPlane(img)}, []*plane{newLumaPlane(img2)}
+	for radius>>(len(p1)-1) > 2 {
+		last := p1[len(p1)-1]
+		if intMin(last.w, last.h)/2 < alignMinSize {
+			break
+		}
+		p1 = append(p1, last.downscale())
+		p2 = append(p2, p2[len(p2)-1].downscale())
+	}
+
+	level := len(p1) - 1
+	r := (radius + 1<<level - 1) >> level
+	off := bestOffset(p1[level], p2[level], image.Point{}, r, r)
+	for level--; level >= 0; level-- {
+		off = bestOffset(p1[level], p2[level], off.Mul(2), 1,
+			radius>>level)
+	}
+	return off, nil
+}
+
+// bestOffset searches the offset around the center within the radius, that
+// minimizes the mean absolute difference of the overlapping areas of the
+// planes. Offsets never exceed the limit on both axes. The smaller offset wins
+// if differences are equal.
+func bestOffset(p1, p2 *plane, center image.Point, radius, limit int) image.Point {
+	best, bestCost := image.Point{}, math.Inf(1)
+	for dy := center.Y - radius; dy <= center.Y+radius; dy++ {
+		for dx := center.X - radius; dx <= center.X+radius; dx++ {
+			off := image.Pt(dx, dy)
+			if intAbs(dx) > limit || intAbs(dy) > limit {
+				continue
+			}
+			cost := shiftCost(p1, p2, off)
+			if cost < bestCost || cost == bestCost &&
+				intAbs(dx)+intAbs(dy) < intAbs(best.X)+intAbs(best.Y) {
+				best, bestCost = off, cost
+			}
+		}
+	}
+	return best
+}
+
+// shiftCost is the mean absolute difference between the plane and the
+// second plane shifted by the offset.
+func shiftCost(p1, p2 *plane, off image.Point) float64 {
+	x1, x2 := intMax(0, -off.X), intMin(p1.w, p2.w-off.X)
+	y1, y2 := intMax(0, -off.Y), intMin(p1.h, p2.h-off.Y)
+	if x1 >= x2 || y1 >= y2 {
+		return math.Inf(1)
+	}
+	sum := 0.0
+	for y := y1; y < y2; y++ {
+		row1 := p1.pix[y*p1.w : (y+1)*p1.w]
+		row2 := p2.pix[(y+off.Y)*p2.w : (y+off.Y+1)*p2.w]
+		for x := x1; x < x2; x++ {
+			sum += math.Abs(row1[x] - row2[x+off.X])
+		}
+	}
+	return sum / float64((x2-x1)*(y2-y1))
+}
+
+// shift moves the second image by the offset back onto the canvas of the
+// image. The area which is not covered by the second image after the shift
+// is filled with the pad color.
+func (img *Image) shift(img2 *Image, off image.Point, pad color.Color) *Image {
+	b := img.Bounds()
+	var m draw.Image = image.NewNRGBA(b)
+	if img.BPC == 8 || img2.BPC == 8 {
+		m = image.NewNRGBA64(b)
+	}
+	draw.Draw(m, b, &image.Uniform{pad}, image.Point{}, draw.Src)
+	draw.Draw(m, b, img2.Image, b.Min.Add(off), draw.Src)
+	return NewImageFromImage(m, img2.Format)
+}
@@ -0,0 +1,88 @@
+package pixmatch
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+// translated creates a copy of the image translated by the offset on the
+// white background.
+func translated(img *Image, off image.Point) *Image {
+	b := img.Bounds()
+	m := image.NewNRGBA(b)
+	draw.Draw(m, b, &image.Uniform{color.White}, image.Point{}, draw.Src)
+	draw.Draw(m, b.Add(off), img.Image, b.Min, draw.Src)
+	return NewImageFromImage(m, img.Format)
+}
+
+func TestAlign(t *testing.T) {
+	imageA, _ := NewImageFromPath("./samples/form-a.png")
+	for _, off := range []image.Point{{0, 0}, {3, -2}, {-7, 9}, {1, 0}} {
+		imageB := translated(imageA, off)
+		got, err := imageA.Align(imageB, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != off {
+			t.Errorf("Expected %v got %v", off, got)
+		}
+	}
+
+	// Translation outside of the radius cannot be found.
+	imageB := translated(imageA, image.Pt(5, 0))
+	got, _ := imageA.Align(imageB, 2)
+	if got.X > 2 {
+		t.Errorf("Expected offset within radius %v got %v", 2, got)
+	}
+
+	_, err := imageA.Align(NewImage(0, 0, DefaultFormat), 2)
+	if err != ErrImageIsEmpty {
+		t.Errorf("Expected %v got %v", ErrImageIsEmpty, err)
+	}
+}
+
+func TestCompare_Align(t *testing.T) {
+	imageA, _ := NewImageFromPath("./samples/form-a.png")
+	imageB := translated(imageA, image.Pt(2, 1))
+
+	res, err := imageA.CompareResult(imageB, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Diff == 0 {
+		t.Errorf("Expected differences got %v", res.Diff)
+	}
+
+	res, err = imageA.CompareResult(imageB, NewOptions().SetAlignRadius(5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Diff != 0 || res.Offset != image.Pt(2, 1) {
+		t.Errorf("Expected %v (%v) got %v (%v)", 0, image.Pt(2, 1), res.Diff,
+			res.Offset)
+	}
+}
+
+func TestCompare_AlignUncovered(t *testing.T) {
+	imageA, _ := NewImageFromPath("./samples/form-a.png")
+	imageB := translated(imageA, image.Pt(2, 1))
+
+	// The right columns are not covered by the shifted image.
+	b := imageA.Bounds()
+	m := image.NewNRGBA(b)
+	draw.Draw(m, b, imageA.Image, b.Min, draw.Src)
+	m.Set(b.Max.X-1, b.Dy()/2, color.RGBA{0xff, 0, 0, 0xff})
+	imageA = NewImageFromImage(m, imageA.Format)
+
+	opts := NewOptions().SetAlignRadius(5).SetPadColor(color.White)
+	res, err := imageA.CompareResult(imageB, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Offset != image.Pt(2, 1) || res.Diff != 1 || res.Extra != 1 {
+		t.Errorf("Expected %v with 1 extra difference got %v with %v/%v",
+			image.Pt(2, 1), res.Offset, res.Diff, res.Extra)
+	}
+}
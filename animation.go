@@ -0,0 +1,287 @@
+package pixmatch
+
+import (
+	"context"
+	"image"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"io"
+	"os"
+)
+
+// Frame is the single frame of the animation.
+type Frame struct {
+	// Image is the fully rendered frame, all previous frames are drawn
+	// according to their disposal methods.
+	*Image
+
+	// Delay is the delay time of the frame in 100ths of a second.
+	Delay int
+
+	// Disposal is the disposal method of the frame, see [image/gif].
+	Disposal byte
+}
+
+// Animation represents the animated image as a sequence of frames.
+// Only GIF format is supported.
+type Animation struct {
+	// Path to the animation in file system.
+	Path string
+
+	// Frames of the animation.
+	Frames []Frame
+
+	// LoopCount controls the number of times an animation will be
+	// restarted during display, see [image/gif.GIF].
+	LoopCount int
+}
+
+// AnimationResult is the outcome of the frame by frame comparison of two
+// animations.
+type AnimationResult struct {
+	// Frames are results of every frame. If animations have different
+	// number of frames, extra frames are counted as entirely different.
+	Frames []*Result
+
+	// FrameCount and FrameCount2 are numbers of frames of both animations.
+	FrameCount, FrameCount2 int
+
+	// DelayMismatch are indexes of frames with different delays.
+	DelayMismatch []int
+
+	// DisposalMismatch are indexes of frames with different disposal
+	// methods.
+	DisposalMismatch []int
+}
+
+// Diff returns the total number of different pixels in all frames.
+func (res *AnimationResult) Diff() int {
+	diff := 0
+	for _, r := range res.Frames {
+		diff += r.Diff
+	}
+	return diff
+}
+
+// FrameDiffs returns the numbers of different pixels of every frame.
+func (res *AnimationResult) FrameDiffs() []int {
+	diffs := make([]int, len(res.Frames))
+	for i, r := range res.Frames {
+		diffs[i] = r.Diff
+	}
+	return diffs
+}
+
+// Equal checks that animations have no differences: the same number of
+// frames, delays, disposal methods and no different pixels.
+func (res *AnimationResult) Equal() bool {
+	return res.FrameCount == res.FrameCount2 &&
+		len(res.DelayMismatch) == 0 &&
+		len(res.DisposalMismatch) == 0 &&
+		res.Diff() == 0
+}
+
+// NewAnimationFromPath creates a new animation instance from the file system
+// path.
+func NewAnimationFromPath(path string) (*Animation, error) {
+	anim := &Animation{Path: path}
+	fp, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer fp.Close()
+	if err := anim.Load(fp); err != nil {
+		return nil, err
+	}
+	return anim, nil
+}
+
+// Load reads all frames of GIF from the reader.
+func (anim *Animation) Load(rd io.Reader) error {
+	g, err := gif.DecodeAll(rd)
+	if err != nil {
+		return err
+	}
+
+	bounds := image.Rect(0, 0, g.Config.Width, g.Config.Height)
+	for _, frame := range g.Image {
+		bounds = bounds.Union(frame.Bounds())
+	}
+
+	// Every frame is drawn over the canvas, then the canvas is disposed.
+	canvas := image.NewRGBA(bounds)
+	anim.Frames = make([]Frame, len(g.Image))
+	anim.LoopCount = g.LoopCount
+	for i, frame := range g.Image {
+		f := Frame{}
+		if i < len(g.Delay) {
+			f.Delay = g.Delay[i]
+		}
+		if i < len(g.Disposal) {
+			f.Disposal = g.Disposal[i]
+		}
+
+		var prev *image.RGBA
+		if f.Disposal == gif.DisposalPrevious {
+			prev = cloneRGBA(canvas)
+		}
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+		f.Image = NewImageFromImage(cloneRGBA(canvas), FormatGIF)
+		f.Path = anim.Path
+
+		switch f.Disposal {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, frame.Bounds(), image.Transparent,
+				image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = prev
+		}
+		anim.Frames[i] = f
+	}
+	return nil
+}
+
+// Compare compares animations frame by frame. If the output is given in the
+// options, the animated GIF of differences is written to it. Delays of the
+// output are taken from the first animation. Frames, which are missing in
+// one of the animations, are entirely marked as different in the output with
+// delays of the other animation.
+func (anim *Animation) Compare(anim2 *Animation,
+	opts *Options) (*AnimationResult, error) {
+	return anim.CompareContext(context.Background(), anim2, opts)
+}
+
+// CompareContext is like [Animation.Compare], but stops when the context is
+// done and returns the error of the context.
+func (anim *Animation) CompareContext(ctx context.Context, anim2 *Animation,
+	opts *Options) (*AnimationResult, error) {
+	if opts == nil {
+		opts = NewOptions()
+	}
+	if len(anim.Frames) == 0 || len(anim2.Frames) == 0 {
+		return nil, ErrImageIsEmpty
+	}
+
+	n := intMin(len(anim.Frames), len(anim2.Frames))
+	total := intMax(len(anim.Frames), len(anim2.Frames))
+	res := &AnimationResult{
+		FrameCount:  len(anim.Frames),
+		FrameCount2: len(anim2.Frames),
+		Frames:      make([]*Result, total),
+	}
+	out := &gif.GIF{LoopCount: anim.LoopCount}
+	render := opts.Output != nil
+	addFrame := func(img *Image, delay int) {
+		out.Image = append(out.Image, toPaletted(img.Image))
+		out.Delay = append(out.Delay, delay)
+	}
+
+	for i := range res.Frames {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if i >= n {
+			f := anim.frameAt(i)
+			if f == nil {
+				f = anim2.frameAt(i)
+			}
+			res.Frames[i] = missingFrameResult(f)
+			if render {
+				addFrame(missingFrame(f, opts), f.Delay)
+			}
+			continue
+		}
+		f1, f2 := anim.Frames[i], anim2.Frames[i]
+		if f1.Delay != f2.Delay {
+			res.DelayMismatch = append(res.DelayMismatch, i)
+		}
+		if f1.Disposal != f2.Disposal {
+			res.DisposalMismatch = append(res.DisposalMismatch, i)
+		}
+
+		r, output, err := f1.compare(ctx, f2.Image, opts, render)
+		if err != nil {
+			return nil, err
+		}
+		res.Frames[i] = r
+		if render {
+			if output == nil {
+				output = f1.grayscale(opts)
+			}
+			addFrame(output, f1.Delay)
+		}
+	}
+
+	if render {
+		if err := gif.EncodeAll(opts.Output, out); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// frameAt returns the frame with the given index or nil if there is no
+// such frame.
+func (anim *Animation) frameAt(i int) *Frame {
+	if i < len(anim.Frames) {
+		return &anim.Frames[i]
+	}
+	return nil
+}
+
+// missingFrameResult returns the result of the frame, which is missing in
+// the other animation, all pixels are counted as different.
+func missingFrameResult(f *Frame) *Result {
+	return &Result{
+		Diff:   f.Size(),
+		Total:  f.Size(),
+		Bounds: f.Bounds(),
+	}
+}
+
+// missingFrame renders the frame, which is missing in the other animation,
+// entirely with the diff color.
+func missingFrame(f *Frame, opts *Options) *Image {
+	c := getDiffColor(opts, 1)
+	if c == nil {
+		c = defaultOptions.DiffColor
+	}
+	output := &Image{Image: image.NewRGBA(f.Bounds()), Format: f.Format}
+	draw.Draw(output.Image.(*image.RGBA), f.Bounds(), &image.Uniform{c},
+		image.Point{}, draw.Src)
+	return output
+}
+
+// grayscale renders the image blended to gray like non-different pixels of
+// the output of the comparison.
+func (img *Image) grayscale(opts *Options) *Image {
+	b := img.Bounds()
+	output := &Image{Image: image.NewRGBA(b), Format: img.Format}
+	if opts.DiffMask {
+		return output
+	}
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r, g, b, a := img.At(x, y).RGBA()
+			gray := NewColor(r, g, b, a).BlendToGray(opts.Alpha)
+			output.Image.(*image.RGBA).Set(x, y, gray)
+		}
+	}
+	return output
+}
+
+// toPaletted converts the image to the paletted one with Plan 9 palette,
+// without dithering to keep colors of the differences sharp.
+func toPaletted(m image.Image) *image.Paletted {
+	p := image.NewPaletted(m.Bounds(), palette.Plan9)
+	draw.Draw(p, p.Bounds(), m, m.Bounds().Min, draw.Src)
+	return p
+}
+
+// cloneRGBA returns a copy of the image.
+func cloneRGBA(m *image.RGBA) *image.RGBA {
+	res := image.NewRGBA(m.Bounds())
+	copy(res.Pix, m.Pix)
+	return res
+}
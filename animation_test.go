@@ -0,0 +1,201 @@
+package pixmatch
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/gif"
+	"reflect"
+	"testing"
+)
+
+// testPalette is the palette of the test animations.
+var testPalette = color.Palette{
+	color.RGBA{0xff, 0xff, 0xff, 0xff},
+	color.RGBA{0xff, 0, 0, 0xff},
+	color.RGBA{0, 0, 0xff, 0xff},
+}
+
+// newTestGIF creates an 8x8 animation: white background frame and frames
+// with 2x2 blue squares at the given points.
+func newTestGIF(delays []int, disposal []byte, squares ...image.Point) *gif.GIF {
+	bg := image.NewPaletted(image.Rect(0, 0, 8, 8), testPalette)
+	g := &gif.GIF{
+		Image:    []*image.Paletted{bg},
+		Delay:    delays,
+		Disposal: disposal,
+		Config:   image.Config{ColorModel: testPalette, Width: 8, Height: 8},
+	}
+	for _, pt := range squares {
+		frame := image.NewPaletted(image.Rect(0, 0, 2, 2).Add(pt), testPalette)
+		for i := range frame.Pix {
+			frame.Pix[i] = 2
+		}
+		g.Image = append(g.Image, frame)
+	}
+	return g
+}
+
+// loadTestGIF encodes and loads the animation.
+func loadTestGIF(t *testing.T, g *gif.GIF) *Animation {
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, g); err != nil {
+		t.Fatal(err)
+	}
+	anim := &Animation{}
+	if err := anim.Load(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return anim
+}
+
+func TestAnimationLoad(t *testing.T) {
+	anim := loadTestGIF(t, newTestGIF(
+		[]int{10, 10, 10, 10},
+		[]byte{gif.DisposalNone, gif.DisposalBackground, gif.DisposalNone,
+			gif.DisposalNone},
+		image.Pt(0, 0), image.Pt(4, 4), image.Pt(6, 0),
+	))
+	if len(anim.Frames) != 4 {
+		t.Fatalf("Expected %v got %v", 4, len(anim.Frames))
+	}
+
+	blue := color.RGBA{0, 0, 0xff, 0xff}
+	pairs := []struct {
+		frame int
+		pt    image.Point
+		want  color.Color
+	}{
+		{0, image.Pt(0, 0), color.RGBA{0xff, 0xff, 0xff, 0xff}},
+		{1, image.Pt(1, 1), blue},
+		{2, image.Pt(5, 5), blue},
+		// The square of the frame 1 is disposed to the background.
+		{2, image.Pt(1, 1), color.RGBA{}},
+		{3, image.Pt(5, 5), blue},
+		{3, image.Pt(7, 1), blue},
+	}
+	for _, p := range pairs {
+		c := color.RGBAModel.Convert(anim.Frames[p.frame].At(p.pt.X, p.pt.Y))
+		if !reflect.DeepEqual(c, p.want) {
+			t.Errorf("Frame %v %v: expected %v got %v", p.frame, p.pt,
+				p.want, c)
+		}
+	}
+}
+
+func TestAnimationCompare(t *testing.T) {
+	disposal := []byte{0, 0, 0}
+	anim1 := loadTestGIF(t, newTestGIF([]int{10, 10, 10}, disposal,
+		image.Pt(0, 0), image.Pt(4, 4)))
+	anim2 := loadTestGIF(t, newTestGIF([]int{10, 10, 20}, disposal,
+		image.Pt(0, 0), image.Pt(5, 4)))
+
+	var buf bytes.Buffer
+	opts := NewOptions().SetIncludeAA(true).SetOutput(&buf)
+	res, err := anim1.Compare(anim2, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []int{0, 0, 4}
+	if !reflect.DeepEqual(res.FrameDiffs(), want) {
+		t.Errorf("Expected %v got %v", want, res.FrameDiffs())
+	}
+	if !reflect.DeepEqual(res.DelayMismatch, []int{2}) {
+		t.Errorf("Expected %v got %v", []int{2}, res.DelayMismatch)
+	}
+	if res.Equal() || res.Diff() != 4 {
+		t.Errorf("Expected %v got %v", 4, res.Diff())
+	}
+
+	out, err := gif.DecodeAll(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Image) != 3 {
+		t.Errorf("Expected %v got %v", 3, len(out.Image))
+	}
+}
+
+func TestAnimationCompare_FrameCount(t *testing.T) {
+	anim1 := loadTestGIF(t, newTestGIF([]int{10, 10}, nil, image.Pt(0, 0)))
+	anim2 := loadTestGIF(t, newTestGIF([]int{10}, nil))
+	res, err := anim1.Compare(anim2, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.FrameCount != 2 || res.FrameCount2 != 1 {
+		t.Errorf("Expected 2 and 1 frames got %v and %v", res.FrameCount,
+			res.FrameCount2)
+	}
+	want := []int{0, 64}
+	if !reflect.DeepEqual(res.FrameDiffs(), want) {
+		t.Errorf("Expected %v got %v", want, res.FrameDiffs())
+	}
+	if res.Equal() {
+		t.Error("Animations should not be equal")
+	}
+}
+
+func TestAnimationCompare_MissingFrames(t *testing.T) {
+	anim1 := loadTestGIF(t, newTestGIF([]int{10, 30}, nil, image.Pt(0, 0)))
+	anim2 := loadTestGIF(t, newTestGIF([]int{20}, nil))
+
+	// Missing frames are marked in the output in both directions.
+	red := color.RGBA{0xff, 0, 0, 0xff}
+	for _, anims := range [][2]*Animation{{anim1, anim2}, {anim2, anim1}} {
+		var buf bytes.Buffer
+		_, err := anims[0].Compare(anims[1], NewOptions().SetOutput(&buf))
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := gif.DecodeAll(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []int{anims[0].Frames[0].Delay, 30}
+		if !reflect.DeepEqual(out.Delay, want) {
+			t.Errorf("Expected %v got %v", want, out.Delay)
+		}
+		if len(out.Image) != 2 {
+			t.Fatalf("Expected %v got %v", 2, len(out.Image))
+		}
+		for _, pt := range []image.Point{{0, 0}, {7, 7}} {
+			c := color.RGBAModel.Convert(out.Image[1].At(pt.X, pt.Y))
+			if c != red {
+				t.Errorf("Expected %v got %v at %v", red, c, pt)
+			}
+		}
+	}
+}
+
+func TestAnimationCompareContext(t *testing.T) {
+	anim := loadTestGIF(t, newTestGIF([]int{10, 10}, nil, image.Pt(0, 0)))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := anim.CompareContext(ctx, anim, nil); err != context.Canceled {
+		t.Errorf("Expected %v got %v", context.Canceled, err)
+	}
+}
+
+func TestNewAnimationFromPath(t *testing.T) {
+	anim, err := NewAnimationFromPath("./samples/landscape-a.gif")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(anim.Frames) != 1 {
+		t.Errorf("Expected %v got %v", 1, len(anim.Frames))
+	}
+	res, err := anim.Compare(anim, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Equal() {
+		t.Error("Animation should be equal to itself")
+	}
+
+	_, err = NewAnimationFromPath("./samples/form-a.png")
+	if err == nil {
+		t.Error("PNG is not an animation")
+	}
+}
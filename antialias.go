@@ -0,0 +1,59 @@
+package pixmatch
+
+import (
+	"image"
+	"math"
+)
+
+// aaEdgeThreshold is the minimum magnitude of the gradient in range [0, 1],
+// that is treated as the edge by the gradient detector.
+const aaEdgeThreshold = 0.2
+
+// gradientAA checks that the point is anti-aliased using the gradient of
+// brightness. The pixel is anti-aliased if it lies on the edge in both
+// images and its brightness is strictly between the darkest and the
+// brightest pixels of the window of the given radius.
+func (img *Image) gradientAA(img2 *Image, pt image.Point, radius int) bool {
+	if img.gradient(pt) < aaEdgeThreshold ||
+		img2.gradient(pt) < aaEdgeThreshold {
+		return false
+	}
+
+	y := img.luma(pt)
+	min, max := math.Inf(1), math.Inf(-1)
+	b := img.Bounds().Intersect(image.Rect(pt.X-radius, pt.Y-radius,
+		pt.X+radius+1, pt.Y+radius+1))
+	for yy := b.Min.Y; yy < b.Max.Y; yy++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if x == pt.X && yy == pt.Y {
+				continue
+			}
+			v := img.luma(image.Pt(x, yy))
+			min = math.Min(min, v)
+			max = math.Max(max, v)
+		}
+	}
+	return min < y && y < max
+}
+
+// gradient is the magnitude of the Sobel gradient of brightness at the point
+// in range [0, 1]. Edge pixels are replicated.
+func (img *Image) gradient(pt image.Point) float64 {
+	b := img.Bounds()
+	at := func(dx, dy int) float64 {
+		x := intMin(intMax(pt.X+dx, b.Min.X), b.Max.X-1)
+		y := intMin(intMax(pt.Y+dy, b.Min.Y), b.Max.Y-1)
+		return img.luma(image.Pt(x, y))
+	}
+	gx := at(1, -1) + 2*at(1, 0) + at(1, 1) -
+		at(-1, -1) - 2*at(-1, 0) - at(-1, 1)
+	gy := at(-1, 1) + 2*at(0, 1) + at(1, 1) -
+		at(-1, -1) - 2*at(0, -1) - at(1, -1)
+	return math.Min(math.Hypot(gx, gy)/(4*0xff), 1)
+}
+
+// luma is the brightness of the pixel in range [0, 255]. Transparent pixels
+// are blended with white color.
+func (img *Image) luma(pt image.Point) float64 {
+	return img.colorAt(img.Position(pt)).rgb().y()
+}
@@ -0,0 +1,81 @@
+package pixmatch
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestCompare_AADetector(t *testing.T) {
+	imageA, _ := NewImageFromPath("./samples/form-a.png")
+	imageB, _ := NewImageFromPath("./samples/form-b.png")
+	all, _ := imageA.Compare(imageB, NewOptions().SetIncludeAA(true))
+
+	tests := []struct {
+		name string
+		opts *Options
+		diff int
+	}{
+		{"Default", NewOptions(), 2909},
+		{"Pixelmatch", NewOptions().SetAARadius(1).SetAANeighbors(2), 2909},
+		{"Radius", NewOptions().SetAARadius(2).SetAANeighbors(6), -1},
+		{"Neighbors", NewOptions().SetAANeighbors(1), -1},
+		{"ZeroNeighbors", NewOptions().SetAANeighbors(0), 2909},
+		{"ZeroRadius", NewOptions().SetAARadius(0), 2909},
+		// Zero value of options has zero radius and neighbors.
+		{"ZeroValue", &Options{Threshold: 0.1, Alpha: 0.1}, 2909},
+		{"Gradient", NewOptions().SetAADetector(AAGradient), -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := imageA.CompareResult(imageB, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Anti-aliased pixels are reported separately.
+			if res.Diff+res.AA != all {
+				t.Errorf("Expected %v got %v+%v", all, res.Diff, res.AA)
+			}
+			if res.AA == 0 {
+				t.Errorf("Expected anti-aliased pixels got %v", res.AA)
+			}
+			if tt.diff >= 0 && res.Diff != tt.diff {
+				t.Errorf("Expected %v got %v", tt.diff, res.Diff)
+			}
+		})
+	}
+}
+
+func TestGradientAA(t *testing.T) {
+	// Black and white halves with the gray anti-aliased column between.
+	m := image.NewNRGBA(image.Rect(0, 0, 9, 9))
+	for y := 0; y < 9; y++ {
+		for x := 0; x < 9; x++ {
+			v := uint8(0)
+			switch {
+			case x == 4:
+				v = 0x80
+			case x > 4:
+				v = 0xff
+			}
+			m.SetNRGBA(x, y, color.NRGBA{v, v, v, 0xff})
+		}
+	}
+	img := NewImageFromImage(m, DefaultFormat)
+	flat := newUniformImage(9, 9, color.White)
+
+	pairs := map[image.Point]bool{
+		{4, 4}: true,
+		{3, 4}: false,
+		{5, 4}: false,
+		{1, 4}: false,
+	}
+	for pt, want := range pairs {
+		if got := img.gradientAA(img, pt, 1); got != want {
+			t.Errorf("%v: expected %v got %v", pt, want, got)
+		}
+	}
+	if img.gradientAA(flat, image.Pt(4, 4), 1) {
+		t.Errorf("Expected %v got %v", false, true)
+	}
+}
@@ -0,0 +1,296 @@
+package pixmatch
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ManifestName is the name of the manifest file in the baseline directory.
+const ManifestName = "pixmatch.json"
+
+// Manifest keeps metadata of the baseline images.
+type Manifest struct {
+	// Images maps relative slash-separated paths to entries.
+	Images map[string]*ManifestEntry `json:"images"`
+}
+
+// ManifestEntry is the metadata of the baseline image.
+type ManifestEntry struct {
+	// Hash is the hex encoded SHA-256 hash of the file.
+	Hash string `json:"hash"`
+
+	// Size is the size of the file in bytes.
+	Size int64 `json:"size"`
+
+	// Width and Height are dimensions of the image.
+	Width  int `json:"width"`
+	Height int `json:"height"`
+
+	// Date is the time when the image was recorded or approved.
+	Date time.Time `json:"date"`
+
+	// Options are options of the comparison used for the image.
+	Options ManifestOptions `json:"options"`
+}
+
+// ManifestOptions are options, which affect the result of the comparison.
+type ManifestOptions struct {
+	Threshold      float64  `json:"threshold"`
+	Metric         string   `json:"metric"`
+	HighPrecision  bool     `json:"highPrecision"`
+	IncludeAA      bool     `json:"includeAA"`
+	MaxDiff        *int     `json:"maxDiff,omitempty"`
+	MaxDiffPercent *float64 `json:"maxDiffPercent,omitempty"`
+	AlignRadius    int      `json:"alignRadius"`
+	ShiftRadius    int      `json:"shiftRadius"`
+}
+
+// Baseline manages the directory of approved images for snapshot testing.
+// Actual images are compared with the baseline, new images are recorded as
+// baseline, changed images are promoted to baseline on approval. Metadata of
+// baseline images is kept in the manifest file, see [ManifestName].
+type Baseline struct {
+	// Dir is the directory of baseline images.
+	Dir string
+
+	// Output is the directory of diff images, see [Batch].
+	Output string
+
+	// Workers is the number of pairs compared concurrently, see [Batch].
+	Workers int
+
+	// Options are options of every comparison.
+	Options *Options
+}
+
+// NewBaseline creates a new baseline of the directory.
+func NewBaseline(dir string) *Baseline {
+	return &Baseline{Dir: dir, Options: NewOptions()}
+}
+
+// SetOutput sets the directory of diff images to the baseline.
+func (bl *Baseline) SetOutput(v string) *Baseline {
+	bl.Output = v
+	return bl
+}
+
+// SetWorkers sets the number of pairs compared concurrently to the baseline.
+func (bl *Baseline) SetWorkers(v int) *Baseline {
+	bl.Workers = v
+	return bl
+}
+
+// SetOptions sets options of every comparison to the baseline.
+func (bl *Baseline) SetOptions(v *Options) *Baseline {
+	bl.Options = v
+	return bl
+}
+
+// Manifest reads the manifest of the baseline. The empty manifest is
+// returned if the file does not exist.
+func (bl *Baseline) Manifest() (*Manifest, error) {
+	m := &Manifest{Images: make(map[string]*ManifestEntry)}
+	data, err := os.ReadFile(filepath.Join(bl.Dir, ManifestName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, err
+	}
+	if m.Images == nil {
+		m.Images = make(map[string]*ManifestEntry)
+	}
+	return m, nil
+}
+
+// saveManifest writes the manifest of the baseline.
+func (bl *Baseline) saveManifest(m *Manifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(bl.Dir, ManifestName),
+		append(data, '\n'), 0644)
+}
+
+// Check compares images of the actual directory with the baseline. Actual
+// images without baseline are recorded as baseline with PairRecorded status,
+// baseline images without actual ones are reported as missing.
+func (bl *Baseline) Check(ctx context.Context,
+	actual string) ([]*PairResult, error) {
+	if err := os.MkdirAll(bl.Dir, 0755); err != nil {
+		return nil, err
+	}
+	pairs, err := NewBatch(bl.Dir, actual).
+		SetOutput(bl.Output).
+		SetWorkers(bl.Workers).
+		SetOptions(bl.Options).
+		Run(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var m *Manifest
+	for _, pair := range pairs {
+		if pair.Status != PairExtra {
+			continue
+		}
+		if m == nil {
+			if m, err = bl.Manifest(); err != nil {
+				return nil, err
+			}
+		}
+		if err := bl.promote(m, pair.Actual, pair.Path); err != nil {
+			pair.Status, pair.Err = PairError, err
+			continue
+		}
+		pair.Status = PairRecorded
+		pair.Expected = filepath.Join(bl.Dir, filepath.FromSlash(pair.Path))
+		pair.Width = m.Images[pair.Path].Width
+		pair.Height = m.Images[pair.Path].Height
+	}
+	if m != nil {
+		if err := bl.saveManifest(m); err != nil {
+			return nil, err
+		}
+	}
+	return pairs, nil
+}
+
+// Approve promotes images of the actual directory to baseline. Paths are
+// relative to the actual directory, all images of the directory are promoted
+// if no paths are given. Images identical to the baseline files are skipped.
+// Returns relative paths of promoted images.
+func (bl *Baseline) Approve(actual string, paths ...string) ([]string, error) {
+	if len(paths) == 0 {
+		all, err := listImages(actual)
+		if err != nil {
+			return nil, err
+		}
+		for path := range all {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+	}
+
+	m, err := bl.Manifest()
+	if err != nil {
+		return nil, err
+	}
+	var approved []string
+	for _, path := range paths {
+		rel := filepath.ToSlash(filepath.Clean(filepath.FromSlash(path)))
+		if filepath.IsAbs(path) || rel == ".." ||
+			strings.HasPrefix(rel, "../") {
+			return approved, fmt.Errorf("%w: %s", ErrInvalidPath, path)
+		}
+		src := filepath.Join(actual, filepath.FromSlash(rel))
+		if !isImageFile(src) {
+			if _, err := os.Stat(src); err != nil {
+				return approved, err
+			}
+			return approved, fmt.Errorf("%w: %s", ErrUnknownFormat, path)
+		}
+		dst := filepath.Join(bl.Dir, filepath.FromSlash(rel))
+		if same, _ := sameFiles(src, dst); same && m.Images[rel] != nil {
+			continue
+		}
+		if err := bl.promote(m, src, rel); err != nil {
+			return approved, err
+		}
+		approved = append(approved, rel)
+	}
+	if len(approved) > 0 {
+		if err := bl.saveManifest(m); err != nil {
+			return approved, err
+		}
+	}
+	return approved, nil
+}
+
+// promote copies the image to the baseline with the relative path and adds
+// its entry to the manifest.
+func (bl *Baseline) promote(m *Manifest, src, rel string) error {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	dst := filepath.Join(bl.Dir, filepath.FromSlash(rel))
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(dst, data, 0644); err != nil {
+		return err
+	}
+
+	opts := bl.Options
+	if opts == nil {
+		opts = NewOptions()
+	}
+	sum := sha256.Sum256(data)
+	entry := &ManifestEntry{
+		Hash: hex.EncodeToString(sum[:]),
+		Size: int64(len(data)),
+		Date: time.Now().UTC().Truncate(time.Second),
+		Options: ManifestOptions{
+			Threshold:      opts.Threshold,
+			Metric:         metricName(opts.Metric),
+			HighPrecision:  opts.HighPrecision,
+			IncludeAA:      opts.IncludeAA,
+			MaxDiff:        opts.MaxDiff,
+			MaxDiffPercent: opts.MaxDiffPercent,
+			AlignRadius:    opts.AlignRadius,
+			ShiftRadius:    opts.ShiftRadius,
+		},
+	}
+	if cfg, _, err := image.DecodeConfig(bytes.NewReader(data)); err == nil {
+		entry.Width, entry.Height = cfg.Width, cfg.Height
+	}
+	m.Images[rel] = entry
+	return nil
+}
+
+// sameFiles checks that both files exist and have the same content.
+func sameFiles(path1, path2 string) (bool, error) {
+	data1, err := os.ReadFile(path1)
+	if err != nil {
+		return false, err
+	}
+	data2, err := os.ReadFile(path2)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(data1, data2), nil
+}
+
+// metricName returns the short name of the metric.
+func metricName(m Metric) string {
+	switch m.(type) {
+	case nil, YIQ:
+		return "yiq"
+	case EuclideanRGB:
+		return "rgb"
+	case CIE76:
+		return "cie76"
+	case CIE94:
+		return "cie94"
+	case CIEDE2000:
+		return "ciede2000"
+	}
+	return fmt.Sprintf("%T", m)
+}
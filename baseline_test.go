@@ -0,0 +1,93 @@
+package pixmatch
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestBaseline(t *testing.T) {
+	dir := t.TempDir()
+	baseline := filepath.Join(dir, "baseline")
+	actual := filepath.Join(dir, "actual")
+	copyFile(t, filepath.Join("samples", "form-a.png"),
+		filepath.Join(actual, "a.png"))
+	copyFile(t, filepath.Join("samples", "gray8-a.png"),
+		filepath.Join(actual, "sub", "b.png"))
+
+	bl := NewBaseline(baseline).SetOptions(NewOptions().SetThreshold(0.05))
+	statuses := func() []PairStatus {
+		t.Helper()
+		pairs, err := bl.Check(context.Background(), actual)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var res []PairStatus
+		for _, pair := range pairs {
+			res = append(res, pair.Status)
+		}
+		return res
+	}
+
+	// New images are recorded.
+	want := []PairStatus{PairRecorded, PairRecorded}
+	if got := statuses(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v got %v", want, got)
+	}
+	m, err := bl.Manifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := m.Images["sub/b.png"]
+	if entry == nil || entry.Width != 16 || entry.Height != 16 ||
+		len(entry.Hash) != 64 || entry.Size == 0 || entry.Date.IsZero() ||
+		entry.Options.Threshold != 0.05 || entry.Options.Metric != "yiq" {
+		t.Errorf("Expected manifest entry got %+v", entry)
+	}
+
+	want = []PairStatus{PairPassed, PairPassed}
+	if got := statuses(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v got %v", want, got)
+	}
+
+	// Changed images fail until they are approved.
+	copyFile(t, filepath.Join("samples", "form-b.png"),
+		filepath.Join(actual, "a.png"))
+	want = []PairStatus{PairFailed, PairPassed}
+	if got := statuses(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v got %v", want, got)
+	}
+	approved, err := bl.Approve(actual)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(approved, []string{"a.png"}) {
+		t.Errorf("Expected %v got %v", []string{"a.png"}, approved)
+	}
+	want = []PairStatus{PairPassed, PairPassed}
+	if got := statuses(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v got %v", want, got)
+	}
+	m, _ = bl.Manifest()
+	if m.Images["a.png"].Hash == entry.Hash {
+		t.Errorf("Expected new hash got %v", m.Images["a.png"].Hash)
+	}
+
+	// Removed images are missing.
+	os.Remove(filepath.Join(actual, "sub", "b.png"))
+	want = []PairStatus{PairPassed, PairMissing}
+	if got := statuses(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v got %v", want, got)
+	}
+
+	if _, err := bl.Approve(actual, "../a.png"); !errors.Is(err,
+		ErrInvalidPath) {
+		t.Errorf("Expected %v got %v", ErrInvalidPath, err)
+	}
+	if _, err := bl.Approve(actual, "sub/b.png"); err == nil {
+		t.Error("Expected error got nil")
+	}
+}
@@ -0,0 +1,312 @@
+package pixmatch
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+// PairStatus is the outcome of the comparison of the pair of images.
+type PairStatus int
+
+const (
+	// PairPassed means that images match.
+	PairPassed PairStatus = iota
+
+	// PairFailed means that images are different. If the budget of
+	// differences is set, images fail only if it is exceeded.
+	PairFailed
+
+	// PairMissing means that the actual image is missing.
+	PairMissing
+
+	// PairExtra means that the expected image is missing.
+	PairExtra
+
+	// PairError means that images cannot be compared, see Err.
+	PairError
+
+	// PairRecorded means that the expected image was missing and the actual
+	// one is recorded as the baseline, see [Baseline.Check].
+	PairRecorded
+)
+
+// String returns the name of the status.
+func (s PairStatus) String() string {
+	switch s {
+	case PairPassed:
+		return "passed"
+	case PairFailed:
+		return "failed"
+	case PairMissing:
+		return "missing"
+	case PairExtra:
+		return "extra"
+	case PairRecorded:
+		return "recorded"
+	}
+	return "error"
+}
+
+// OK reports whether the status is not a failure.
+func (s PairStatus) OK() bool {
+	return s == PairPassed || s == PairRecorded
+}
+
+// PairResult is the outcome of the comparison of the pair of images with
+// the same relative path.
+type PairResult struct {
+	// Path is the relative path of the images.
+	Path string
+
+	// Expected and Actual are paths of the images in the file system. The
+	// path is empty if the image is missing.
+	Expected, Actual string
+
+	// Diff is the path of the written diff image. It is empty if there is
+	// no output or no difference.
+	Diff string
+
+	// Status is the outcome of the comparison.
+	Status PairStatus
+
+	// Result is the result of the comparison, nil if images are not
+	// compared.
+	Result *Result
+
+	// Width and Height are dimensions of the expected image.
+	Width, Height int
+
+	// Err is the error of the comparison if Status is PairError.
+	Err error
+}
+
+// Batch compares images of two directories, paired by relative paths.
+// Pairs are compared concurrently by the pool of workers.
+type Batch struct {
+	// Expected and Actual are directories of images. Only files recognized
+	// as images by their content are compared, see [DetectFormat].
+	Expected, Actual string
+
+	// Output is the directory, where diff images are written in the same
+	// tree as images. No diff images are written if it is empty.
+	Output string
+
+	// Workers is the number of pairs compared concurrently. The default is
+	// runtime.GOMAXPROCS(0).
+	Workers int
+
+	// Options are options of every comparison. Output of the options is
+	// ignored. Progress is called every time a pair is compared with the
+	// number of compared pairs and the total number of pairs. If pairs are
+	// compared concurrently, every pair is compared by a single worker,
+	// unless the number of workers is set in the options.
+	Options *Options
+}
+
+// NewBatch creates a new batch comparison of the directories.
+func NewBatch(expected, actual string) *Batch {
+	return &Batch{Expected: expected, Actual: actual, Options: NewOptions()}
+}
+
+// SetOutput sets the directory of diff images to the batch.
+func (b *Batch) SetOutput(v string) *Batch {
+	b.Output = v
+	return b
+}
+
+// SetWorkers sets the number of pairs compared concurrently to the batch.
+func (b *Batch) SetWorkers(v int) *Batch {
+	b.Workers = v
+	return b
+}
+
+// SetOptions sets options of every comparison to the batch.
+func (b *Batch) SetOptions(v *Options) *Batch {
+	b.Options = v
+	return b
+}
+
+// Run compares all pairs of images. Results are sorted by relative paths.
+// Errors of single comparisons are reported in results, the error is
+// returned if directories cannot be read or the context is done.
+func (b *Batch) Run(ctx context.Context) ([]*PairResult, error) {
+	expected, err := listImages(b.Expected)
+	if err != nil {
+		return nil, err
+	}
+	actual, err := listImages(b.Actual)
+	if err != nil {
+		return nil, err
+	}
+
+	var pairs []*PairResult
+	for path := range expected {
+		pair := &PairResult{Path: path,
+			Expected: filepath.Join(b.Expected, filepath.FromSlash(path))}
+		if actual[path] {
+			pair.Actual = filepath.Join(b.Actual, filepath.FromSlash(path))
+		} else {
+			pair.Status = PairMissing
+		}
+		pairs = append(pairs, pair)
+	}
+	for path := range actual {
+		if !expected[path] {
+			pairs = append(pairs, &PairResult{Path: path,
+				Actual: filepath.Join(b.Actual, filepath.FromSlash(path)),
+				Status: PairExtra})
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		return pairs[i].Path < pairs[j].Path
+	})
+
+	workers := b.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	opts := b.Options
+	if opts == nil {
+		opts = NewOptions()
+	}
+	// Pairs are already compared concurrently, so bands of rows are not.
+	o := *opts
+	o.Progress = nil
+	if o.Workers <= 0 && workers > 1 {
+		o.Workers = 1
+	}
+
+	// Only pairs of existing images are compared, statuses of others are
+	// already known.
+	total, done := 0, 0
+	for _, pair := range pairs {
+		if pair.Status == PairPassed {
+			total++
+		}
+	}
+	var mu sync.Mutex
+	jobs := make(chan *PairResult)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for pair := range jobs {
+				b.compare(ctx, pair, &o)
+				if opts.Progress != nil && ctx.Err() == nil {
+					mu.Lock()
+					done++
+					opts.Progress(done, total)
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for _, pair := range pairs {
+		if pair.Status == PairPassed && ctx.Err() == nil {
+			jobs <- pair
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return pairs, nil
+}
+
+// compare compares the pair of images with the options and writes the diff
+// image.
+func (b *Batch) compare(ctx context.Context, pair *PairResult,
+	opts *Options) {
+	fail := func(err error) {
+		pair.Status, pair.Err = PairError, err
+	}
+
+	img1, err := NewImageFromPath(pair.Expected)
+	if err != nil {
+		fail(err)
+		return
+	}
+	pair.Width, pair.Height = img1.Bounds().Dx(), img1.Bounds().Dy()
+	img2, err := NewImageFromPath(pair.Actual)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	o := *opts
+	o.Output = nil
+	if b.Output != "" {
+		pair.Diff = filepath.Join(b.Output, filepath.FromSlash(pair.Path))
+		if err := os.MkdirAll(filepath.Dir(pair.Diff), 0755); err != nil {
+			fail(err)
+			return
+		}
+		fp, err := os.Create(pair.Diff)
+		if err != nil {
+			fail(err)
+			return
+		}
+		defer func() {
+			fp.Close()
+			// Keep only diff images with differences.
+			if pair.Result == nil || pair.Result.Diff == 0 ||
+				pair.Result.Partial {
+				os.Remove(pair.Diff)
+				pair.Diff = ""
+			}
+		}()
+		o.Output = fp
+	}
+
+	res, err := img1.CompareResultContext(ctx, img2, &o)
+	if err != nil {
+		fail(err)
+		return
+	}
+	pair.Result = res
+	budget := opts.hasBudget()
+	if budget && res.Exceeded || !budget && res.Diff > 0 {
+		pair.Status = PairFailed
+	}
+}
+
+// listImages returns relative paths of all images in the directory tree.
+func listImages(dir string) (map[string]bool, error) {
+	paths := make(map[string]bool)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry,
+		err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		if !isImageFile(path) {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		paths[filepath.ToSlash(rel)] = true
+		return nil
+	})
+	return paths, err
+}
+
+// isImageFile checks that the file is an image by its content.
+func isImageFile(path string) bool {
+	fp, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer fp.Close()
+	header := make([]byte, sniffLen)
+	n, _ := fp.Read(header)
+	return DetectFormat(header[:n]) != ""
+}
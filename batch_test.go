@@ -0,0 +1,135 @@
+package pixmatch
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// copyFile copies the file creating all directories of the destination.
+func copyFile(t *testing.T, src, dst string) {
+	t.Helper()
+	data, err := os.ReadFile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dst, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBatch(t *testing.T) {
+	dir := t.TempDir()
+	expected := filepath.Join(dir, "expected")
+	actual := filepath.Join(dir, "actual")
+	output := filepath.Join(dir, "output")
+	files := map[string]string{
+		"expected/a.png":     "form-a.png",
+		"actual/a.png":       "form-b.png",
+		"expected/sub/b.png": "gray8-a.png",
+		"actual/sub/b.png":   "gray8-a.png",
+		"expected/c.png":     "form-a.png",
+		"actual/d.png":       "form-a.png",
+		"expected/e.png":     "corrupted.png",
+		"actual/e.png":       "corrupted.png",
+		"expected/notes.txt": "not-image",
+	}
+	for dst, src := range files {
+		copyFile(t, filepath.Join("samples", src), filepath.Join(dir, dst))
+	}
+
+	pairs, err := NewBatch(expected, actual).SetOutput(output).SetWorkers(2).
+		Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		path   string
+		status PairStatus
+		diff   int
+	}{
+		{"a.png", PairFailed, 2909},
+		{"c.png", PairMissing, 0},
+		{"d.png", PairExtra, 0},
+		{"e.png", PairError, 0},
+		{"sub/b.png", PairPassed, 0},
+	}
+	if len(pairs) != len(want) {
+		t.Fatalf("Expected %v got %v", len(want), len(pairs))
+	}
+	for i, w := range want {
+		pair := pairs[i]
+		if pair.Path != w.path || pair.Status != w.status {
+			t.Errorf("Expected %v %v got %v %v", w.path, w.status, pair.Path,
+				pair.Status)
+		}
+		if pair.Result != nil && pair.Result.Diff != w.diff {
+			t.Errorf("Expected %v got %v", w.diff, pair.Result.Diff)
+		}
+	}
+	if pairs[3].Err == nil {
+		t.Errorf("Expected error got %v", pairs[3].Err)
+	}
+
+	// Diff images are written only for different images.
+	if pairs[0].Diff != filepath.Join(output, "a.png") {
+		t.Errorf("Expected %v got %v", filepath.Join(output, "a.png"),
+			pairs[0].Diff)
+	}
+	if _, err := NewImageFromPath(pairs[0].Diff); err != nil {
+		t.Error(err)
+	}
+	if _, err := os.Stat(filepath.Join(output, "sub", "b.png")); err == nil {
+		t.Errorf("Expected no diff image for %v", pairs[4].Path)
+	}
+
+	// The budget of differences is not exceeded.
+	opts := NewOptions().SetMaxDiff(3000)
+	pairs, _ = NewBatch(expected, actual).SetOptions(opts).
+		Run(context.Background())
+	if pairs[0].Status != PairPassed {
+		t.Errorf("Expected %v got %v", PairPassed, pairs[0].Status)
+	}
+
+	_, err = NewBatch(filepath.Join(dir, "nonexists"), actual).
+		Run(context.Background())
+	if err == nil {
+		t.Error("Expected error got nil")
+	}
+}
+
+func TestBatch_Progress(t *testing.T) {
+	dir := t.TempDir()
+	expected := filepath.Join(dir, "expected")
+	actual := filepath.Join(dir, "actual")
+	for _, name := range []string{"a.png", "b.png", "c.png", "d.png"} {
+		copyFile(t, "samples/form-a.png", filepath.Join(expected, name))
+		copyFile(t, "samples/form-b.png", filepath.Join(actual, name))
+	}
+	copyFile(t, "samples/form-a.png", filepath.Join(expected, "e.png"))
+
+	// Calls are serialized and count pairs, not rows.
+	var calls []int
+	opts := NewOptions().SetProgress(func(done, total int) {
+		if total != 4 {
+			t.Errorf("Expected %v got %v", 4, total)
+		}
+		calls = append(calls, done)
+	})
+	_, err := NewBatch(expected, actual).SetWorkers(3).SetOptions(opts).
+		Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(calls, []int{1, 2, 3, 4}) {
+		t.Errorf("Expected %v got %v", []int{1, 2, 3, 4}, calls)
+	}
+	if opts.Workers != 0 {
+		t.Errorf("Expected %v got %v", 0, opts.Workers)
+	}
+}
@@ -1,6 +1,13 @@
 package pixmatch
 
-import "testing"
+import (
+	"bytes"
+	"image"
+	"io"
+	"math"
+	"sync"
+	"testing"
+)
 
 var benchOpts = NewOptions()
 
@@ -118,3 +125,186 @@ func BenchmarkCompare_DifferentIncludeAA(b *testing.B) {
 		images[0].Compare(images[1], benchOpts)
 	}
 }
+
+// largeImages creates two tall images with scattered differences.
+func largeImages() []*Image {
+	r := image.Rect(0, 0, 512, 8192)
+	m1, m2 := image.NewNRGBA(r), image.NewNRGBA(r)
+	for i := range m1.Pix {
+		m1.Pix[i] = uint8(i / 4 % 251)
+		m2.Pix[i] = m1.Pix[i]
+		if i%997 == 0 {
+			m2.Pix[i] ^= 0xff
+		}
+	}
+	return []*Image{
+		NewImageFromImage(m1, DefaultFormat),
+		NewImageFromImage(m2, DefaultFormat),
+	}
+}
+
+func BenchmarkCompare_Large(b *testing.B) {
+	images := largeImages()
+	opts := NewOptions()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		images[0].Compare(images[1], opts)
+	}
+}
+
+// comparePerRow compares images the way it was done before the pool of
+// workers: every row is compared by its own goroutine, and every different
+// or anti-aliased pixel is counted under the mutex. It is kept for
+// benchmarks only, the output is not rendered.
+func comparePerRow(img, img2 *Image, opts *Options) *Result {
+	metric := opts.Metric
+	if metric == nil {
+		metric = YIQ{}
+	}
+	maxDelta := metric.Limit(opts.Threshold)
+	skipping := len(opts.Ignore) > 0 || len(opts.Include) > 0
+
+	res := &Result{Total: img.Size()}
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	wg.Add(img.Bounds().Dy())
+	for y := img.Bounds().Min.Y; y < img.Bounds().Max.Y; y++ {
+		go func(y int) {
+			defer wg.Done()
+			for x := img.Bounds().Min.X; x < img.Bounds().Max.X; x++ {
+				point := image.Pt(x, y)
+				if skipping && opts.Skipped(point) {
+					mu.Lock()
+					res.Ignored++
+					mu.Unlock()
+					continue
+				}
+				pos := img.Position(point)
+				delta := img.MetricDelta(img2, pos, pos, metric)
+				if math.Abs(delta) <= maxDelta || opts.ShiftRadius > 0 &&
+					img.nearMatch(img2, point, opts.ShiftRadius, metric,
+						maxDelta, opts.HighPrecision) &&
+					img2.nearMatch(img, point, opts.ShiftRadius, metric,
+						maxDelta, opts.HighPrecision) {
+					continue
+				}
+				if !opts.IncludeAA && (img.detectAA(img2, point, opts) ||
+					img2.detectAA(img, point, opts)) {
+					mu.Lock()
+					res.AA++
+					mu.Unlock()
+					continue
+				}
+				mu.Lock()
+				res.addDiff(point, delta)
+				mu.Unlock()
+			}
+		}(y)
+	}
+	wg.Wait()
+	res.Total -= res.Ignored
+	return res
+}
+
+// BenchmarkCompare_LargePerRow is the baseline of BenchmarkCompare_Large with
+// the goroutine per row.
+func BenchmarkCompare_LargePerRow(b *testing.B) {
+	images := largeImages()
+	opts := NewOptions()
+	want, _ := images[0].CompareResult(images[1], opts)
+	if got := comparePerRow(images[0], images[1], opts); got.Diff != want.Diff ||
+		got.AA != want.AA || got.Total != want.Total {
+		b.Fatalf("Expected %v/%v/%v got %v/%v/%v", want.Diff, want.AA,
+			want.Total, got.Diff, got.AA, got.Total)
+	}
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		comparePerRow(images[0], images[1], opts)
+	}
+}
+
+func BenchmarkCompare_LargeSingleWorker(b *testing.B) {
+	images := largeImages()
+	opts := NewOptions().SetWorkers(1)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		images[0].Compare(images[1], opts)
+	}
+}
+
+func BenchmarkCompare_LargeManyWorkers(b *testing.B) {
+	images := largeImages()
+	opts := NewOptions().SetWorkers(images[0].Bounds().Dy())
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		images[0].Compare(images[1], opts)
+	}
+}
+
+func BenchmarkCompare_LargeOutput(b *testing.B) {
+	images := largeImages()
+	opts := NewOptions().SetOutput(io.Discard)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		images[0].Compare(images[1], opts)
+	}
+}
+
+func BenchmarkCompare_LargeMaxDiff(b *testing.B) {
+	images := largeImages()
+	opts := NewOptions().SetMaxDiff(100)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		images[0].Compare(images[1], opts)
+	}
+}
+
+func BenchmarkCompareStream_Large(b *testing.B) {
+	images := largeImages()
+	data := make([][]byte, len(images))
+	for i, img := range images {
+		var buf bytes.Buffer
+		img.Save(&buf)
+		data[i] = buf.Bytes()
+	}
+	opts := NewOptions().SetOutput(io.Discard)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		CompareStream(bytes.NewReader(data[0]), bytes.NewReader(data[1]), opts)
+	}
+}
+
+// scatteredClusters creates the grid of single pixel clusters, spaced by the
+// gap of 2 pixels.
+func scatteredClusters(n int) []Cluster {
+	clusters := make([]Cluster, 0, n*n)
+	for y := 0; y < n; y++ {
+		for x := 0; x < n; x++ {
+			pt := image.Pt(x*3, y*3)
+			clusters = append(clusters, Cluster{
+				Bounds: image.Rectangle{pt, pt.Add(image.Pt(1, 1))},
+				Pixels: 1,
+			})
+		}
+	}
+	return clusters
+}
+
+func BenchmarkMergeClusters_Separate(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		mergeClusters(scatteredClusters(100), 1)
+	}
+}
+
+func BenchmarkMergeClusters_Merged(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		mergeClusters(scatteredClusters(100), 2)
+	}
+}
@@ -0,0 +1,176 @@
+package pixmatch
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"sort"
+)
+
+// Cluster is the connected region of the different pixels.
+type Cluster struct {
+	// Bounds is the bounding box of the cluster.
+	Bounds image.Rectangle
+
+	// Pixels is the number of different pixels in the cluster.
+	Pixels int
+
+	// sumX and sumY are sums of coordinates to calculate centroid.
+	sumX, sumY int
+}
+
+// Centroid returns the center of mass of the cluster.
+func (c Cluster) Centroid() (float64, float64) {
+	if c.Pixels == 0 {
+		return 0, 0
+	}
+	n := float64(c.Pixels)
+	return float64(c.sumX)/n + 0.5, float64(c.sumY)/n + 0.5
+}
+
+// merge merges the cluster c2 into c.
+func (c *Cluster) merge(c2 Cluster) {
+	c.Bounds = c.Bounds.Union(c2.Bounds)
+	c.Pixels += c2.Pixels
+	c.sumX += c2.sumX
+	c.sumY += c2.sumY
+}
+
+// near checks that the gap between bounding boxes of clusters is not greater
+// than dist pixels.
+func (c Cluster) near(c2 Cluster, dist int) bool {
+	dx := intMax(c.Bounds.Min.X-c2.Bounds.Max.X, c2.Bounds.Min.X-c.Bounds.Max.X)
+	dy := intMax(c.Bounds.Min.Y-c2.Bounds.Max.Y, c2.Bounds.Min.Y-c.Bounds.Max.Y)
+	return dx <= dist && dy <= dist
+}
+
+// findClusters groups different pixels into connected clusters. Slice diffs
+// is the map of different pixels of the rectangle r, row by row.
+// Connectivity is 4 or 8.
+func findClusters(diffs []bool, r image.Rectangle, connectivity int) []Cluster {
+	w, h := r.Dx(), r.Dy()
+	seen := make([]bool, len(diffs))
+	neighbors := []image.Point{{1, 0}, {-1, 0}, {0, 1}, {0, -1}}
+	if connectivity == 8 {
+		neighbors = append(neighbors,
+			image.Pt(1, 1), image.Pt(-1, -1), image.Pt(1, -1), image.Pt(-1, 1))
+	}
+
+	clusters := []Cluster{}
+	stack := []image.Point{}
+	for i, d := range diffs {
+		if !d || seen[i] {
+			continue
+		}
+		seen[i] = true
+		cl := Cluster{}
+		stack = append(stack[:0], image.Pt(i%w, i/w))
+		for len(stack) > 0 {
+			pt := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			abs := pt.Add(r.Min)
+			cl.merge(Cluster{
+				Bounds: image.Rectangle{abs, abs.Add(image.Pt(1, 1))},
+				Pixels: 1,
+				sumX:   abs.X,
+				sumY:   abs.Y,
+			})
+			for _, n := range neighbors {
+				np := pt.Add(n)
+				if np.X < 0 || np.Y < 0 || np.X >= w || np.Y >= h {
+					continue
+				}
+				j := np.Y*w + np.X
+				if diffs[j] && !seen[j] {
+					seen[j] = true
+					stack = append(stack, np)
+				}
+			}
+		}
+		clusters = append(clusters, cl)
+	}
+	return clusters
+}
+
+// mergeClusters merges clusters closer than dist pixels to each other, until
+// there is nothing to merge. Every pass sweeps clusters sorted by the left
+// edge and joins near ones with union-find. Merged clusters are larger and
+// may become near other ones, so passes are repeated while clusters merge.
+func mergeClusters(clusters []Cluster, dist int) []Cluster {
+	for {
+		sort.SliceStable(clusters, func(i, j int) bool {
+			return clusters[i].Bounds.Min.X < clusters[j].Bounds.Min.X
+		})
+		parent := make([]int, len(clusters))
+		for i := range parent {
+			parent[i] = i
+		}
+		find := func(i int) int {
+			for parent[i] != i {
+				parent[i] = parent[parent[i]]
+				i = parent[i]
+			}
+			return i
+		}
+
+		merged := false
+		for i := range clusters {
+			for j := i + 1; j < len(clusters); j++ {
+				// Clusters further to the right are too far too.
+				if clusters[j].Bounds.Min.X-clusters[i].Bounds.Max.X > dist {
+					break
+				}
+				if !clusters[i].near(clusters[j], dist) {
+					continue
+				}
+				if a, b := find(i), find(j); a != b {
+					parent[b] = a
+					merged = true
+				}
+			}
+		}
+		if !merged {
+			return clusters
+		}
+
+		roots := make(map[int]int)
+		res := make([]Cluster, 0, len(clusters))
+		for i, c := range clusters {
+			root := find(i)
+			if k, ok := roots[root]; ok {
+				res[k].merge(c)
+				continue
+			}
+			roots[root] = len(res)
+			res = append(res, c)
+		}
+		clusters = res
+	}
+}
+
+// sortClusters sorts clusters from the largest to the smallest one.
+func sortClusters(clusters []Cluster) {
+	sort.SliceStable(clusters, func(i, j int) bool {
+		if clusters[i].Pixels != clusters[j].Pixels {
+			return clusters[i].Pixels > clusters[j].Pixels
+		}
+		a, b := clusters[i].Bounds.Min, clusters[j].Bounds.Min
+		return a.Y < b.Y || a.Y == b.Y && a.X < b.X
+	})
+}
+
+// DrawClusters draws bounding boxes of the clusters on the image with the
+// given color.
+func DrawClusters(dst draw.Image, clusters []Cluster, c color.Color) {
+	for _, cl := range clusters {
+		b := cl.Bounds
+		for x := b.Min.X; x < b.Max.X; x++ {
+			dst.Set(x, b.Min.Y, c)
+			dst.Set(x, b.Max.Y-1, c)
+		}
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			dst.Set(b.Min.X, y, c)
+			dst.Set(b.Max.X-1, y, c)
+		}
+	}
+}
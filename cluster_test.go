@@ -0,0 +1,112 @@
+package pixmatch
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// clusterMask is a 6x4 map of different pixels: two diagonal pixels and
+// a separate 2x2 square.
+var clusterMask = []bool{
+	true, false, false, false, false, false,
+	false, true, false, false, true, true,
+	false, false, false, false, true, true,
+	false, false, false, false, false, false,
+}
+
+func TestFindClusters(t *testing.T) {
+	r := image.Rect(0, 0, 6, 4)
+	pairs := map[int]int{4: 3, 8: 2}
+	for conn, want := range pairs {
+		clusters := findClusters(clusterMask, r, conn)
+		if len(clusters) != want {
+			t.Errorf("Connectivity %v: expected %v got %v", conn, want,
+				len(clusters))
+		}
+	}
+
+	clusters := findClusters(clusterMask, r, 8)
+	sortClusters(clusters)
+	want := image.Rect(4, 1, 6, 3)
+	if !clusters[0].Bounds.Eq(want) || clusters[0].Pixels != 4 {
+		t.Errorf("Expected %v (4px) got %v (%vpx)", want,
+			clusters[0].Bounds, clusters[0].Pixels)
+	}
+	x, y := clusters[0].Centroid()
+	if x != 5 || y != 2 {
+		t.Errorf("Expected centroid (5,2) got (%v,%v)", x, y)
+	}
+}
+
+func TestMergeClusters(t *testing.T) {
+	r := image.Rect(0, 0, 6, 4)
+	clusters := mergeClusters(findClusters(clusterMask, r, 8), 1)
+	if len(clusters) != 2 {
+		t.Errorf("Expected %v got %v", 2, len(clusters))
+	}
+	clusters = mergeClusters(findClusters(clusterMask, r, 8), 2)
+	if len(clusters) != 1 || clusters[0].Pixels != 6 {
+		t.Errorf("Expected single cluster got %+v", clusters)
+	}
+
+	// The last pixel is near the merged cluster only.
+	clusters = nil
+	for _, pt := range []image.Point{{4, -2}, {0, 0}, {2, 2}} {
+		clusters = append(clusters, Cluster{
+			Bounds: image.Rectangle{pt, pt.Add(image.Pt(1, 1))}, Pixels: 1})
+	}
+	clusters = mergeClusters(clusters, 1)
+	want := image.Rect(0, -2, 5, 3)
+	if len(clusters) != 1 || !clusters[0].Bounds.Eq(want) {
+		t.Errorf("Expected %v got %+v", want, clusters)
+	}
+}
+
+func TestDrawClusters(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 5, 5))
+	c := color.RGBA{0xff, 0, 0, 0xff}
+	DrawClusters(img, []Cluster{{Bounds: image.Rect(1, 1, 4, 4)}}, c)
+	pairs := map[image.Point]bool{
+		{1, 1}: true,
+		{3, 2}: true,
+		{2, 2}: false,
+		{0, 0}: false,
+	}
+	for pt, want := range pairs {
+		if res := img.RGBAAt(pt.X, pt.Y) == c; res != want {
+			t.Errorf("%v: expected %v got %v", pt, want, res)
+		}
+	}
+}
+
+func TestCompare_Clusters(t *testing.T) {
+	img1, _ := NewImageFromPath("./samples/form-a.png")
+	img2, _ := NewImageFromPath("./samples/form-b.png")
+	res, err := img1.CompareResult(img2, NewOptions().SetConnectivity(8))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Clusters) == 0 {
+		t.Fatal("Expected clusters")
+	}
+	sum := 0
+	for i, cl := range res.Clusters {
+		sum += cl.Pixels
+		if i > 0 && cl.Pixels > res.Clusters[i-1].Pixels {
+			t.Error("Clusters are not sorted")
+		}
+	}
+	if sum != res.Diff {
+		t.Errorf("Expected %v got %v", res.Diff, sum)
+	}
+	big := res.ClustersAtLeast(res.Clusters[0].Pixels)
+	if len(big) == 0 || len(big) > len(res.Clusters) {
+		t.Errorf("Invalid number of large clusters %v", len(big))
+	}
+
+	_, err = img1.CompareResult(img2, NewOptions().SetConnectivity(6))
+	if err != ErrInvalidConnectivity {
+		t.Errorf("Expected %v got %v", ErrInvalidConnectivity, err)
+	}
+}
@@ -3,3 +3,5 @@ module github.com/dknight/go-pixmatch/cmd/pixmatch
 go 1.19
 
 require github.com/dknight/go-pixmatch v1.1.0
+
+require golang.org/x/image v0.18.0 // indirect
@@ -5,17 +5,22 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"flag"
 	"fmt"
+	"image"
+	"io"
 	"log"
 	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 
 	"github.com/dknight/go-pixmatch"
 )
 
-var outputUsage = "Output file path."
+var outputUsage = "Output file path. Output directory of diff images with" +
+	" dir command."
 var thresholdUsage = "Threshold of the maximum color delta." +
 	" Values range [0..1] (default 0.1)."
 var alphaUsage = "Alpha channel factor. Values range [0..1]. (default 0.1)"
@@ -34,6 +39,57 @@ var percentUsage = "Display the difference in percent, instead of pixels" +
 var keepUsage = "Keep empty output files. Valid only with -o flag."
 var nUsage = "Do not output the trailing newline."
 var watchUsage = "Experimental: Watch for input pair of images"
+var sizeUsage = "Policy to compare images with different dimensions:" +
+	" strict, overlap or pad (default strict)."
+var anchorUsage = "Position of the second image if dimensions are" +
+	" different: topleft or center (default topleft)."
+var ssimUsage = "Display the structural similarity index (SSIM) in range" +
+	" [-1..1], instead of pixels (default false)."
+var metricUsage = "Metric of the color difference: yiq, rgb, cie76, cie94" +
+	" or ciede2000 (default yiq). Threshold of CIE metrics is ΔE/100."
+var preciseUsage = "Compare 16 bits per channel of 16-bit images and write" +
+	" 16-bit output (default false)."
+var heatmapUsage = "Render the differences as heatmap with colormap:" +
+	" viridis, inferno or gray (default none)."
+var faintUsage = "Render under-threshold differences faintly. Works only" +
+	" with -heatmap flag (default false)."
+var legendUsage = "Add legend strip of the colormap. Works only with" +
+	" -heatmap flag (default false)."
+var compositeUsage = "Write composite image of expected, actual and diff" +
+	" panels to the output: horizontal or vertical (default none)."
+var labelsUsage = "Add labels to the panels of composite image (default false)."
+var onionUsage = "Add onion skin overlay panel to composite image" +
+	" (default false)."
+var animUsage = "Compare animated GIFs frame by frame, display the" +
+	" difference of every frame on a separate line (default false)."
+var maxDiffUsage = "Budget of different pixels. Comparison stops as soon as" +
+	" the budget is exceeded and exits with status 106 (default -1, disabled)."
+var maxDiffPercentUsage = "Budget of different pixels in percent, like" +
+	" -maxdiff (default -1, disabled)."
+var renderExceededUsage = "Compare entirely and write the output even if" +
+	" the budget is exceeded (default false)."
+var streamUsage = "Compare PNG images row by row with low memory usage. Only" +
+	" non-interlaced PNG images are supported (default false)."
+var alignUsage = "Radius of the search for the translation of the second" +
+	" image. Images are aligned before the comparison if it is positive" +
+	" (default 0)."
+var shiftUsage = "Radius of the per-pixel shift tolerance. Different pixels" +
+	" matching any pixel nearby are not counted (default 0)."
+var aaDetectorUsage = "Algorithm to detect anti-aliased pixels: pixelmatch" +
+	" or gradient (default pixelmatch)."
+var aaRadiusUsage = "Radius of the window to detect anti-aliased pixels" +
+	" (default 1)."
+var aaNeighborsUsage = "Maximum number of the same neighbors of" +
+	" anti-aliased pixels (default 2)."
+var resampleUsage = "Resample the second image to dimensions of the first" +
+	" one with the filter: nearest, bilinear or lanczos (default none)."
+var jobsUsage = "Number of pairs of images compared concurrently with dir" +
+	" command (default GOMAXPROCS)."
+var formatUsage = "Format of the output: text, json, junit or tap" +
+	" (default text). Not supported with -ssim flag."
+var htmlUsage = "Write self-contained HTML report with thumbnails and" +
+	" the swipe viewer to the path."
+var padColorUsage = "Color to pad images with -size=pad (default 00000000)."
 
 var output string
 var threshold float64
@@ -48,15 +104,47 @@ var percent bool
 var keep bool
 var n bool
 var watch bool
+var size string
+var anchor string
+var padColor string
+var ssim bool
+var metric string
+var precise bool
+var heatmap string
+var faint bool
+var legend bool
+var composite string
+var labels bool
+var onion bool
+var anim bool
+var maxDiff int
+var maxDiffPercent float64
+var renderExceeded bool
+var stream bool
+var align int
+var shift int
+var aaDetector string
+var aaRadius int
+var aaNeighbors int
+var resample string
+var jobs int
+var reportFormat string
+var htmlReport string
 
 var fpOutout *os.File
 
+// usedOptions are options of the comparison to report.
+var usedOptions *pixmatch.Options
+
 func init() {
 	flag.Usage = func() {
 		out := flag.CommandLine.Output()
 		fmt.Fprintf(out, "Usage of %s:\n", os.Args[0])
 		fmt.Fprintln(out)
 		fmt.Fprintln(out, "pixelmatch [flags] image1.png image2.png")
+		fmt.Fprintln(out, "pixelmatch [flags] dir expected/ actual/")
+		fmt.Fprintln(out, "pixelmatch [flags] check baseline/ actual/")
+		fmt.Fprintln(out, "pixelmatch [flags] approve baseline/ actual/ [path ...]")
 		fmt.Fprintln(out)
 		fmt.Fprintln(out, "Colors are in hexadecimal format: (0x)RRGGBBAA.")
 		fmt.Fprintln(out, "Examples:")
@@ -80,8 +168,53 @@ func init() {
 	flag.BoolVar(&keep, "keep", false, keepUsage)
 	flag.BoolVar(&n, "n", false, nUsage)
 	flag.BoolVar(&watch, "w", false, watchUsage)
+	flag.StringVar(&size, "size", "", sizeUsage)
+	flag.StringVar(&anchor, "anchor", "", anchorUsage)
+	flag.StringVar(&padColor, "padcolor", "", padColorUsage)
+	flag.BoolVar(&ssim, "ssim", false, ssimUsage)
+	flag.StringVar(&metric, "metric", "", metricUsage)
+	flag.BoolVar(&precise, "precise", false, preciseUsage)
+	flag.StringVar(&heatmap, "heatmap", "", heatmapUsage)
+	flag.BoolVar(&faint, "faint", false, faintUsage)
+	flag.BoolVar(&legend, "legend", false, legendUsage)
+	flag.StringVar(&composite, "composite", "", compositeUsage)
+	flag.BoolVar(&labels, "labels", false, labelsUsage)
+	flag.BoolVar(&onion, "onion", false, onionUsage)
+	flag.BoolVar(&anim, "anim", false, animUsage)
+	flag.IntVar(&maxDiff, "maxdiff", -1, maxDiffUsage)
+	flag.Float64Var(&maxDiffPercent, "maxdiffpercent", -1, maxDiffPercentUsage)
+	flag.BoolVar(&renderExceeded, "renderexceeded", false, renderExceededUsage)
+	flag.BoolVar(&stream, "stream", false, streamUsage)
+	flag.IntVar(&align, "align", 0, alignUsage)
+	flag.IntVar(&shift, "shift", 0, shiftUsage)
+	flag.StringVar(&aaDetector, "aadetector", "", aaDetectorUsage)
+	flag.IntVar(&aaRadius, "aaradius", 1, aaRadiusUsage)
+	flag.IntVar(&aaNeighbors, "aaneighbors", 2, aaNeighborsUsage)
+	flag.StringVar(&resample, "resample", "", resampleUsage)
+	flag.IntVar(&jobs, "j", 0, jobsUsage)
+	flag.StringVar(&reportFormat, "format", "", formatUsage)
+	flag.StringVar(&htmlReport, "html", "", htmlUsage)
+}
+
+// parseFlags parses and validates flags and arguments of the command line.
+func parseFlags() {
 	flag.Parse()
 
+	switch reportFormat {
+	case "", "text":
+		reportFormat = ""
+	case formatJSON, formatJUnit, formatTAP:
+		if ssim {
+			reportFormat = ""
+			exitErr(pixmatch.ExitInvalidInput,
+				fmt.Errorf("format is not supported with ssim"))
+		}
+	default:
+		f := reportFormat
+		reportFormat = ""
+		exitErr(pixmatch.ExitInvalidInput, fmt.Errorf("invalid format: %s", f))
+	}
+
 	// Just display version.
 	if version {
 		fmt.Println(pixmatch.GetVersion())
@@ -92,25 +225,143 @@ func init() {
 		flag.Usage()
 		os.Exit(pixmatch.ExitOk)
 	}
-	if argsCount < 2 && !watch {
+	if argsCount < 2 && !watch || isCommand(flag.Arg(0)) && argsCount < 3 {
 		exitErr(pixmatch.ExitMissingImage, pixmatch.ErrMissingImage)
 	}
 }
 
-func RunComparison(paths []string) (int, int) {
+func RunComparison(paths []string) (*pixmatch.Result, int) {
 	opts := pixmatch.NewOptions()
-	images := make([]*pixmatch.Image, 2)
+	setupOptions(opts)
+	setupOutput(opts)
+	images := loadImages(paths)
+
+	// Compare images
+	res, err := images[0].CompareResult(images[1], opts)
+	if err != nil {
+		exitCompareErr(err)
+	}
+
+	return res, images[0].Size()
+}
+
+// RunStream compares PNG images row by row without loading them entirely.
+func RunStream(paths []string) (*pixmatch.Result, int) {
+	opts := pixmatch.NewOptions()
+	setupOptions(opts)
+	setupOutput(opts)
 
+	files := make([]*os.File, 2)
+	for i, p := range paths {
+		fp, err := os.Open(p)
+		if err != nil {
+			exitErr(pixmatch.ExitFSFail, err)
+		}
+		defer fp.Close()
+		files[i] = fp
+	}
+	res, err := pixmatch.CompareStream(files[0], files[1], opts)
+	if err != nil {
+		exitCompareErr(err)
+	}
+	return res, res.Total + res.Ignored
+}
+
+// RunDir compares images of two directories paired by relative paths. Diff
+// images are written to the output directory.
+func RunDir(dirs []string) ([]*pixmatch.PairResult, error) {
+	opts := pixmatch.NewOptions()
 	setupOptions(opts)
+	return pixmatch.NewBatch(dirs[0], dirs[1]).
+		SetOutput(output).
+		SetWorkers(jobs).
+		SetOptions(opts).
+		Run(context.Background())
+}
+
+// RunCheck compares images of the actual directory with the baseline
+// directory. New images are recorded as baseline.
+func RunCheck(dirs []string) ([]*pixmatch.PairResult, error) {
+	opts := pixmatch.NewOptions()
+	setupOptions(opts)
+	return pixmatch.NewBaseline(dirs[0]).
+		SetOutput(output).
+		SetWorkers(jobs).
+		SetOptions(opts).
+		Check(context.Background(), dirs[1])
+}
+
+// RunApprove promotes images of the actual directory to the baseline
+// directory. All images are promoted if no paths are given.
+func RunApprove(args []string) ([]string, error) {
+	opts := pixmatch.NewOptions()
+	setupOptions(opts)
+	return pixmatch.NewBaseline(args[0]).
+		SetOptions(opts).
+		Approve(args[1], args[2:]...)
+}
 
-	// Load images
+// RunSSIM calculates the structural similarity index of two images. SSIM map
+// is written to the output if it is given.
+func RunSSIM(paths []string) float64 {
+	images := loadImages(paths)
+	m, err := images[0].SSIMMap(images[1])
+	if err != nil {
+		exitCompareErr(err)
+	}
+	if output != "" {
+		fp, err := os.Create(output)
+		if err != nil {
+			exitErr(pixmatch.ExitFSFail, err)
+		}
+		defer fp.Close()
+		out := m.Image()
+		if cm := colormap(); cm != nil {
+			out = m.Colorize(cm)
+		}
+		if err := out.Save(fp); err != nil {
+			exitErr(pixmatch.ExitFSFail, err)
+		}
+	}
+	return m.Mean()
+}
+
+// RunAnimation compares animations frame by frame. Returns differences of
+// every frame and the size of the frame.
+func RunAnimation(paths []string) ([]int, int) {
+	opts := pixmatch.NewOptions()
+	setupOptions(opts)
+	setupOutput(opts)
+
+	anims := make([]*pixmatch.Animation, 2)
+	for i, p := range paths {
+		a, err := pixmatch.NewAnimationFromPath(p)
+		if err != nil {
+			exitErr(pixmatch.ExitFSFail, err)
+		}
+		anims[i] = a
+	}
+	res, err := anims[0].Compare(anims[1], opts)
+	if err != nil {
+		exitCompareErr(err)
+	}
+	return res.FrameDiffs(), anims[0].Frames[0].Size()
+}
+
+func loadImages(paths []string) []*pixmatch.Image {
+	images := make([]*pixmatch.Image, 2)
 	var wg sync.WaitGroup
 	for i := range paths {
 		wg.Add(1)
 		go func(i int) {
 			defer func() {
 				if r := recover(); r != nil {
-					exitErr(pixmatch.ExitFSFail, r.(error))
+					err := r.(error)
+					if err == image.ErrFormat ||
+						err == pixmatch.ErrUnsupportedFormat {
+						exitErr(pixmatch.ExitUnknownFormat, err)
+					}
+					exitErr(pixmatch.ExitFSFail, err)
 				}
 			}()
 			img, err := pixmatch.NewImageFromPath(paths[i])
@@ -122,29 +373,18 @@ func RunComparison(paths []string) (int, int) {
 		}(i)
 	}
 	wg.Wait()
+	return images
+}
 
-	// Compare images
-	px, err := images[0].Compare(images[1], opts)
-	if err != nil {
-		switch err {
-		case pixmatch.ErrDimensionsDoNotMatch:
-			exitErr(pixmatch.ExitDimensionsNotEqual, err)
-		case pixmatch.ErrImageIsEmpty:
-			exitErr(pixmatch.ExitEmptyImage, err)
-		case pixmatch.ErrUnknownFormat:
-			exitErr(pixmatch.ExitUnknownFormat, err)
-		default:
-			exitErr(pixmatch.ExitUnknown, err)
-		}
-	}
-
-	return px, images[0].Size()
+func exitCompareErr(err error) {
+	exitErr(errCode(err), err)
 }
 
 func main() {
+	parseFlags()
 	paths := make([]string, 2)
 	args := flag.Args()
-	var px int
+	var res *pixmatch.Result
 	var size int
 	// TODO deal with watch()
 	if watch {
@@ -162,25 +402,202 @@ func main() {
 			// }
 			paths = strings.Fields(line)
 			if len(paths) > 0 {
-				px, size = RunComparison(paths)
+				res, size = RunComparison(paths)
 			}
 		}
 	}
 
+	if len(args) > 0 && isCommand(args[0]) {
+		os.Exit(runCommand(args, os.Stdout))
+	}
+
 	for i, arg := range args {
 		paths[i] = arg
 	}
-	px, size = RunComparison(paths)
+	if ssim {
+		format := "%.4f"
+		if !n {
+			format += "\n"
+		}
+		fmt.Fprintf(os.Stdout, format, RunSSIM(paths))
+		return
+	}
+	if anim {
+		diffs, size := RunAnimation(paths)
+		if reportFormat != "" {
+			pairs := make([]*pixmatch.PairResult, len(diffs))
+			w, h := imageSize(paths[0])
+			for i, d := range diffs {
+				pairs[i] = newPair(paths, &pixmatch.Result{Diff: d, Total: size})
+				pairs[i].Path = fmt.Sprintf("%s#%d", paths[1], i)
+				pairs[i].Width, pairs[i].Height = w, h
+			}
+			exitReport(newReport(usedOptions, pairs, pixmatch.ExitOk))
+		}
+		for _, d := range diffs {
+			fmt.Fprint(os.Stdout, format(d, percent, size))
+		}
+		return
+	}
+	if stream {
+		res, size = RunStream(paths)
+	} else {
+		res, size = RunComparison(paths)
+	}
 
-	// If no diference remove file.
-	if output != "" && px <= 0 && !keep {
+	// If no diference or nothing is rendered remove file.
+	if output != "" && (res.Diff <= 0 && !keep || res.Partial) {
 		os.Remove(output)
 	}
 
-	output := format(px, percent, size)
+	code := pixmatch.ExitOk
+	if res.Exceeded {
+		code = pixmatch.ExitDiffExceeded
+	}
+	if reportFormat != "" || htmlReport != "" {
+		pair := newPair(paths, res)
+		pair.Width, pair.Height = imageSize(paths[0])
+		if _, err := os.Stat(output); output != "" && err == nil {
+			pair.Diff = output
+		}
+		pairs := []*pixmatch.PairResult{pair}
+		writeHTML(pairs)
+		if reportFormat != "" {
+			exitReport(newReport(usedOptions, pairs, code))
+		}
+	}
+
+	output := format(res.Diff, percent, size)
 	fmt.Fprint(os.Stdout, output)
 
 	fpOutout.Close()
+	os.Exit(code)
+}
+
+// runCommand runs dir, check or approve command of arguments and writes the
+// outcome to w. Returns the exit code of the program.
+func runCommand(args []string, w io.Writer) int {
+	if len(args) < 3 {
+		return writeErr(w, args, pixmatch.ExitMissingImage,
+			pixmatch.ErrMissingImage)
+	}
+	var pairs []*pixmatch.PairResult
+	var err error
+	switch args[0] {
+	case "dir":
+		pairs, err = RunDir(args[1:3])
+	case "check":
+		pairs, err = RunCheck(args[1:3])
+	case "approve":
+		approved, err := RunApprove(args[1:])
+		if err != nil {
+			return writeErr(w, args, errCode(err), err)
+		}
+		for _, path := range approved {
+			fmt.Fprintf(w, "approved\t%s\n", path)
+		}
+		fmt.Fprintf(w, "%d approved\n", len(approved))
+		return pixmatch.ExitOk
+	default:
+		return writeErr(w, args, pixmatch.ExitInvalidInput,
+			fmt.Errorf("invalid command: %s", args[0]))
+	}
+	if err != nil {
+		return writeErr(w, args, pixmatch.ExitFSFail, err)
+	}
+	return writePairs(w, pairs)
+}
+
+// writePairs writes results of pairs of directories. Returns non-zero code
+// if any pair fails.
+func writePairs(w io.Writer, pairs []*pixmatch.PairResult) int {
+	writeHTML(pairs)
+	if reportFormat != "" {
+		rep := newReport(usedOptions, pairs, pixmatch.ExitOk)
+		if !rep.Passed {
+			rep.Code = pixmatch.ExitBatchFailed
+		}
+		return writeReport(w, rep)
+	}
+	failed := false
+	counts := make(map[pixmatch.PairStatus]int)
+	for _, pair := range pairs {
+		counts[pair.Status]++
+		failed = failed || !pair.Status.OK()
+		fmt.Fprintf(w, "%s\t%s", pair.Status, pair.Path)
+		if pair.Result != nil {
+			fmt.Fprint(w, "\t", strings.TrimSpace(
+				format(pair.Result.Diff, percent, pair.Width*pair.Height)))
+		} else if pair.Err != nil {
+			fmt.Fprint(w, "\t", pair.Err)
+		}
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintf(w, "%d passed, %d failed, %d missing, %d extra,"+
+		" %d errors", counts[pixmatch.PairPassed],
+		counts[pixmatch.PairFailed], counts[pixmatch.PairMissing],
+		counts[pixmatch.PairExtra], counts[pixmatch.PairError])
+	if n := counts[pixmatch.PairRecorded]; n > 0 {
+		fmt.Fprintf(w, ", %d recorded", n)
+	}
+	fmt.Fprintln(w)
+	if failed {
+		return pixmatch.ExitBatchFailed
+	}
+	return pixmatch.ExitOk
+}
+
+// isCommand checks that the argument is the name of the command.
+func isCommand(arg string) bool {
+	return arg == "dir" || arg == "check" || arg == "approve"
+}
+
+// newPair creates the result of the comparison of the pair of images. The
+// pair fails if the budget is exceeded, or if there is any difference without
+// the budget.
+func newPair(paths []string, res *pixmatch.Result) *pixmatch.PairResult {
+	pair := &pixmatch.PairResult{
+		Path:     filepath.ToSlash(paths[1]),
+		Expected: paths[0],
+		Actual:   paths[1],
+		Result:   res,
+	}
+	budget := maxDiff >= 0 || maxDiffPercent >= 0
+	if budget && res.Exceeded || !budget && res.Diff > 0 {
+		pair.Status = pixmatch.PairFailed
+	}
+	return pair
+}
+
+// writeHTML writes the HTML report of pairs if it is requested.
+func writeHTML(pairs []*pixmatch.PairResult) {
+	if htmlReport == "" {
+		return
+	}
+	fp, err := os.Create(htmlReport)
+	if err != nil {
+		exitErr(pixmatch.ExitFSFail, err)
+	}
+	defer fp.Close()
+	if err := pixmatch.NewHTMLReport().Write(fp, pairs); err != nil {
+		exitErr(pixmatch.ExitFSFail, err)
+	}
+}
+
+// exitReport writes the report in the machine-readable format and exits with
+// the code of the report.
+func exitReport(rep *report) {
+	os.Exit(writeReport(os.Stdout, rep))
+}
+
+// writeReport writes the report in the machine-readable format. Returns the
+// code of the report.
+func writeReport(w io.Writer, rep *report) int {
+	if err := rep.write(w, reportFormat); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return pixmatch.ExitUnknown
+	}
+	return rep.Code
 }
 
 func format(d int, isPct bool, size int) string {
@@ -199,7 +616,8 @@ func format(d int, isPct bool, size int) string {
 	return fmt.Sprintf(format, d)
 }
 
-func setupOptions(opts *pixmatch.Options) {
+// setupOutput creates the output file.
+func setupOutput(opts *pixmatch.Options) {
 	if output != "" {
 		fp, err := os.Create(output)
 		if err != nil {
@@ -207,6 +625,10 @@ func setupOptions(opts *pixmatch.Options) {
 		}
 		opts.SetOutput(fp)
 	}
+}
+
+func setupOptions(opts *pixmatch.Options) {
+	usedOptions = opts
 	if threshold != 0 {
 		opts.SetThreshold(threshold)
 	}
@@ -223,6 +645,16 @@ func setupOptions(opts *pixmatch.Options) {
 		}
 		opts.SetAAColor(color)
 	}
+	switch aaDetector {
+	case "", "pixelmatch":
+		opts.SetAADetector(pixmatch.AAPixelmatch)
+	case "gradient":
+		opts.SetAADetector(pixmatch.AAGradient)
+	default:
+		exitErr(pixmatch.ExitInvalidInput,
+			fmt.Errorf("invalid anti-aliasing detector: %s", aaDetector))
+	}
+	opts.SetAARadius(aaRadius).SetAANeighbors(aaNeighbors)
 	if diffColor != "" {
 		color, err := pixmatch.HexStringToColor(diffColor)
 		if err != nil {
@@ -240,13 +672,129 @@ func setupOptions(opts *pixmatch.Options) {
 	if mask {
 		opts.SetDiffMask(true)
 	}
+	switch metric {
+	case "", "yiq":
+		opts.SetMetric(pixmatch.YIQ{})
+	case "rgb":
+		opts.SetMetric(pixmatch.EuclideanRGB{})
+	case "cie76":
+		opts.SetMetric(pixmatch.CIE76{})
+	case "cie94":
+		opts.SetMetric(pixmatch.CIE94{})
+	case "ciede2000":
+		opts.SetMetric(pixmatch.CIEDE2000{})
+	default:
+		exitErr(pixmatch.ExitInvalidInput,
+			fmt.Errorf("invalid metric: %s", metric))
+	}
+	opts.SetHighPrecision(precise)
+	if cm := colormap(); cm != nil {
+		opts.SetColormap(cm).SetFaint(faint).SetLegend(legend)
+	}
+	switch composite {
+	case "":
+		opts.SetComposite(pixmatch.LayoutNone)
+	case "horizontal":
+		opts.SetComposite(pixmatch.LayoutHorizontal)
+	case "vertical":
+		opts.SetComposite(pixmatch.LayoutVertical)
+	default:
+		exitErr(pixmatch.ExitInvalidInput,
+			fmt.Errorf("invalid composite layout: %s", composite))
+	}
+	opts.SetLabels(labels).SetOnion(onion)
+	switch size {
+	case "", "strict":
+		opts.SetSizePolicy(pixmatch.SizeStrict)
+	case "overlap":
+		opts.SetSizePolicy(pixmatch.SizeOverlap)
+	case "pad":
+		opts.SetSizePolicy(pixmatch.SizePad)
+	default:
+		exitErr(pixmatch.ExitInvalidInput,
+			fmt.Errorf("invalid size policy: %s", size))
+	}
+	switch anchor {
+	case "", "topleft":
+		opts.SetAnchor(pixmatch.AnchorTopLeft)
+	case "center":
+		opts.SetAnchor(pixmatch.AnchorCenter)
+	default:
+		exitErr(pixmatch.ExitInvalidInput,
+			fmt.Errorf("invalid anchor: %s", anchor))
+	}
+	switch resample {
+	case "", "none":
+		opts.SetResample(pixmatch.ResampleNone)
+	case "nearest":
+		opts.SetResample(pixmatch.ResampleNearest)
+	case "bilinear":
+		opts.SetResample(pixmatch.ResampleBilinear)
+	case "lanczos":
+		opts.SetResample(pixmatch.ResampleLanczos)
+	default:
+		exitErr(pixmatch.ExitInvalidInput,
+			fmt.Errorf("invalid resample filter: %s", resample))
+	}
+	opts.SetAlignRadius(align).SetShiftRadius(shift)
+	opts.SetMaxDiff(maxDiff).
+		SetMaxDiffPercent(maxDiffPercent).
+		SetRenderExceeded(renderExceeded)
+	if padColor != "" {
+		color, err := pixmatch.HexStringToColor(padColor)
+		if err != nil {
+			exitErr(pixmatch.ExitInvalidInput, err)
+		}
+		opts.SetPadColor(color)
+	}
+}
+
+func colormap() pixmatch.Colormap {
+	switch heatmap {
+	case "":
+		return nil
+	case "viridis":
+		return pixmatch.Viridis
+	case "inferno":
+		return pixmatch.Inferno
+	case "gray":
+		return pixmatch.Grayscale
+	}
+	exitErr(pixmatch.ExitInvalidInput,
+		fmt.Errorf("invalid heatmap: %s", heatmap))
+	return nil
 }
 
 func exitErr(status int, errs ...error) {
+	os.Exit(writeErr(os.Stdout, flag.Args(), status, errs...))
+}
+
+// writeErr prints errors and writes the report of the failed pair of
+// arguments if it is requested. Returns the status.
+func writeErr(w io.Writer, args []string, status int, errs ...error) int {
 	for _, e := range errs {
 		if e.Error() != "" {
 			fmt.Fprintln(os.Stderr, e.Error())
 		}
 	}
-	os.Exit(status)
+	if reportFormat != "" && len(errs) > 0 {
+		return writeReport(w, newReport(usedOptions,
+			[]*pixmatch.PairResult{errorPair(args, errs[0])}, status))
+	}
+	return status
+}
+
+// errorPair creates the result of the pair of images given in arguments,
+// which cannot be compared.
+func errorPair(args []string, err error) *pixmatch.PairResult {
+	if len(args) > 0 && isCommand(args[0]) {
+		args = args[1:]
+	}
+	pair := &pixmatch.PairResult{Status: pixmatch.PairError, Err: err}
+	if len(args) > 1 {
+		pair.Path = filepath.ToSlash(args[1])
+		pair.Expected, pair.Actual = args[0], args[1]
+		pair.Width, pair.Height = imageSize(args[0])
+	}
+	return pair
 }
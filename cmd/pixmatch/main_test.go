@@ -1,5 +1,14 @@
 package main
 
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dknight/go-pixmatch"
+)
+
 //
 //------------------
 //< Dragons be here! >
@@ -21,3 +30,85 @@ package main
 //              ///-._ _ _ _ _ _ _}^ - - - - ~                     ~-- ,.-~
 //                                                                 /.-~
 //
+
+// copyImage copies the sample image into the directory.
+func copyImage(t *testing.T, sample, dir, name string) {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("..", "..", "samples", sample))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRunCommand(t *testing.T) {
+	expected, same, different := t.TempDir(), t.TempDir(), t.TempDir()
+	copyImage(t, "form-a.png", expected, "form.png")
+	copyImage(t, "form-a.png", same, "form.png")
+	copyImage(t, "form-b.png", different, "form.png")
+
+	tests := []struct {
+		name   string
+		args   []string
+		code   int
+		output string
+	}{
+		{"Passed", []string{"dir", expected, same}, pixmatch.ExitOk,
+			"passed\tform.png\t0\n" +
+				"1 passed, 0 failed, 0 missing, 0 extra, 0 errors\n"},
+		{"Failed", []string{"dir", expected, different},
+			pixmatch.ExitBatchFailed, "failed\tform.png\t2909\n" +
+				"0 passed, 1 failed, 0 missing, 0 extra, 0 errors\n"},
+		{"Missing", []string{"dir", expected, t.TempDir()},
+			pixmatch.ExitBatchFailed, "missing\tform.png\n" +
+				"0 passed, 0 failed, 1 missing, 0 extra, 0 errors\n"},
+		{"NotEnoughArgs", []string{"check", expected},
+			pixmatch.ExitMissingImage, ""},
+		{"InvalidCommand", []string{"diff", expected, same},
+			pixmatch.ExitInvalidInput, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if code := runCommand(tt.args, &buf); code != tt.code {
+				t.Errorf("Expected %v got %v", tt.code, code)
+			}
+			if buf.String() != tt.output {
+				t.Errorf("Expected %q got %q", tt.output, buf.String())
+			}
+		})
+	}
+}
+
+func TestRunCommand_Baseline(t *testing.T) {
+	baseline, actual := t.TempDir(), t.TempDir()
+	copyImage(t, "form-a.png", actual, "form.png")
+	run := func(args []string, code int, output string) {
+		t.Helper()
+		var buf bytes.Buffer
+		if got := runCommand(args, &buf); got != code {
+			t.Errorf("%v: expected %v got %v", args[0], code, got)
+		}
+		if buf.String() != output {
+			t.Errorf("%v: expected %q got %q", args[0], output, buf.String())
+		}
+	}
+
+	run([]string{"check", baseline, actual}, pixmatch.ExitOk,
+		"recorded\tform.png\n"+
+			"0 passed, 0 failed, 0 missing, 0 extra, 0 errors, 1 recorded\n")
+
+	copyImage(t, "form-b.png", actual, "form.png")
+	run([]string{"check", baseline, actual}, pixmatch.ExitBatchFailed,
+		"failed\tform.png\t2909\n"+
+			"0 passed, 1 failed, 0 missing, 0 extra, 0 errors\n")
+	run([]string{"approve", baseline, actual}, pixmatch.ExitOk,
+		"approved\tform.png\n1 approved\n")
+	run([]string{"check", baseline, actual}, pixmatch.ExitOk,
+		"passed\tform.png\t0\n"+
+			"1 passed, 0 failed, 0 missing, 0 extra, 0 errors\n")
+	run([]string{"approve", baseline, actual, "../form.png"},
+		pixmatch.ExitInvalidInput, "")
+}
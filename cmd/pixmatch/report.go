@@ -0,0 +1,329 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/dknight/go-pixmatch"
+)
+
+// Formats of the machine-readable output.
+const (
+	formatJSON  = "json"
+	formatJUnit = "junit"
+	formatTAP   = "tap"
+)
+
+// report is the machine-readable outcome of the run.
+type report struct {
+	Threshold      float64       `json:"threshold"`
+	Metric         string        `json:"metric"`
+	IncludeAA      bool          `json:"includeAA"`
+	MaxDiff        int           `json:"maxDiff"`
+	MaxDiffPercent float64       `json:"maxDiffPercent"`
+	Passed         bool          `json:"passed"`
+	Code           int           `json:"code"`
+	Pairs          []*pairReport `json:"pairs"`
+}
+
+// pairReport is the outcome of the comparison of the pair of images.
+type pairReport struct {
+	Name     string  `json:"name"`
+	Expected string  `json:"expected,omitempty"`
+	Actual   string  `json:"actual,omitempty"`
+	Status   string  `json:"status"`
+	Width    int     `json:"width"`
+	Height   int     `json:"height"`
+	Diff     int     `json:"diff"`
+	Percent  float64 `json:"percent"`
+	AA       int     `json:"aa"`
+	DiffPath string  `json:"diffPath,omitempty"`
+	Code     int     `json:"code"`
+	Error    string  `json:"error,omitempty"`
+
+	// ok reports that the pair is not a failure.
+	ok bool
+}
+
+// newReport creates the report of pairs, compared with options. Code is the
+// exit code of the program.
+func newReport(opts *pixmatch.Options, pairs []*pixmatch.PairResult,
+	code int) *report {
+	if opts == nil {
+		opts = pixmatch.NewOptions()
+	}
+	rep := &report{
+		Threshold:      opts.Threshold,
+		Metric:         metric,
+		IncludeAA:      opts.IncludeAA,
+		MaxDiff:        -1,
+		MaxDiffPercent: -1,
+		Passed:         true,
+		Code:           code,
+		Pairs:          make([]*pairReport, 0, len(pairs)),
+	}
+	if rep.Metric == "" {
+		rep.Metric = "yiq"
+	}
+	// Disabled budgets are reported as -1, like flags.
+	if opts.MaxDiff != nil {
+		rep.MaxDiff = *opts.MaxDiff
+	}
+	if opts.MaxDiffPercent != nil {
+		rep.MaxDiffPercent = *opts.MaxDiffPercent
+	}
+	for _, pair := range pairs {
+		p := &pairReport{
+			Name:     pair.Path,
+			Expected: pair.Expected,
+			Actual:   pair.Actual,
+			Status:   pair.Status.String(),
+			Width:    pair.Width,
+			Height:   pair.Height,
+			DiffPath: pair.Diff,
+			ok:       pair.Status.OK(),
+		}
+		if pair.Result != nil {
+			p.Diff, p.AA = pair.Result.Diff, pair.Result.AA
+			p.Percent = pair.Result.Percent()
+		}
+		switch pair.Status {
+		case pixmatch.PairFailed:
+			if pair.Result != nil && pair.Result.Exceeded {
+				p.Code = pixmatch.ExitDiffExceeded
+			}
+		case pixmatch.PairMissing, pixmatch.PairExtra:
+			p.Code = pixmatch.ExitMissingImage
+		case pixmatch.PairError:
+			p.Code = errCode(pair.Err)
+		}
+		if pair.Err != nil {
+			p.Error = pair.Err.Error()
+		}
+		rep.Passed = rep.Passed && pair.Status.OK()
+		rep.Pairs = append(rep.Pairs, p)
+	}
+	return rep
+}
+
+// write writes the report in the format.
+func (rep *report) write(w io.Writer, format string) error {
+	switch format {
+	case formatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(rep)
+	case formatJUnit:
+		return rep.writeJUnit(w)
+	case formatTAP:
+		return rep.writeTAP(w)
+	}
+	return fmt.Errorf("invalid format: %s", format)
+}
+
+// summary describes the outcome of the comparison of the pair in one line.
+func (p *pairReport) summary() string {
+	switch p.Status {
+	case pixmatch.PairMissing.String():
+		return "actual image is missing"
+	case pixmatch.PairExtra.String():
+		return "expected image is missing"
+	case pixmatch.PairError.String():
+		return p.Error
+	}
+	return fmt.Sprintf("%d different pixels (%.2f%%)", p.Diff, p.Percent)
+}
+
+// details lists paths and counts of the pair.
+func (p *pairReport) details() [][2]string {
+	d := [][2]string{
+		{"expected", p.Expected},
+		{"actual", p.Actual},
+		{"status", p.Status},
+		{"width", strconv.Itoa(p.Width)},
+		{"height", strconv.Itoa(p.Height)},
+		{"diff", strconv.Itoa(p.Diff)},
+		{"percent", strconv.FormatFloat(p.Percent, 'f', 2, 64)},
+		{"aa", strconv.Itoa(p.AA)},
+		{"code", strconv.Itoa(p.Code)},
+	}
+	if p.DiffPath != "" {
+		d = append(d, [2]string{"diffPath", p.DiffPath})
+	}
+	if p.Error != "" {
+		d = append(d, [2]string{"error", p.Error})
+	}
+	return d
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",cdata"`
+}
+
+type junitOutput struct {
+	Text string `xml:",cdata"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+	SystemOut *junitOutput  `xml:"system-out"`
+}
+
+type junitTestSuite struct {
+	Name       string          `xml:"name,attr"`
+	Tests      int             `xml:"tests,attr"`
+	Failures   int             `xml:"failures,attr"`
+	Errors     int             `xml:"errors,attr"`
+	Properties []junitProperty `xml:"properties>property"`
+	TestCases  []junitTestCase `xml:"testcase"`
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+// writeJUnit writes the report as JUnit XML. Every pair is the test case,
+// different, missing and extra images are failures. Diff images are
+// referenced as attachments.
+func (rep *report) writeJUnit(w io.Writer) error {
+	suite := junitTestSuite{
+		Name:  "pixmatch",
+		Tests: len(rep.Pairs),
+		Properties: []junitProperty{
+			{"threshold", strconv.FormatFloat(rep.Threshold, 'g', -1, 64)},
+			{"metric", rep.Metric},
+			{"includeAA", strconv.FormatBool(rep.IncludeAA)},
+			{"maxDiff", strconv.Itoa(rep.MaxDiff)},
+			{"maxDiffPercent",
+				strconv.FormatFloat(rep.MaxDiffPercent, 'g', -1, 64)},
+		},
+	}
+	for _, p := range rep.Pairs {
+		var out strings.Builder
+		for _, d := range p.details() {
+			fmt.Fprintf(&out, "%s: %s\n", d[0], d[1])
+		}
+		if p.DiffPath != "" {
+			fmt.Fprintf(&out, "[[ATTACHMENT|%s]]\n", p.DiffPath)
+		}
+		tc := junitTestCase{
+			Name:      p.Name,
+			ClassName: "pixmatch",
+			SystemOut: &junitOutput{out.String()},
+		}
+		msg := &junitMessage{Message: p.summary(), Type: p.Status,
+			Text: out.String()}
+		switch {
+		case p.ok:
+		case p.Status == pixmatch.PairError.String():
+			tc.Error = msg
+			suite.Errors++
+		default:
+			tc.Failure = msg
+			suite.Failures++
+		}
+		suite.TestCases = append(suite.TestCases, tc)
+	}
+	suites := junitTestSuites{
+		Name:     suite.Name,
+		Tests:    suite.Tests,
+		Failures: suite.Failures,
+		Errors:   suite.Errors,
+		Suites:   []junitTestSuite{suite},
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(suites); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// writeTAP writes the report in Test Anything Protocol version 13. Details
+// of every pair are in YAML blocks.
+func (rep *report) writeTAP(w io.Writer) error {
+	var out strings.Builder
+	fmt.Fprintln(&out, "TAP version 13")
+	fmt.Fprintf(&out, "1..%d\n", len(rep.Pairs))
+	fmt.Fprintf(&out, "# threshold: %g, metric: %s, includeAA: %t,"+
+		" maxDiff: %d, maxDiffPercent: %g\n", rep.Threshold, rep.Metric,
+		rep.IncludeAA, rep.MaxDiff, rep.MaxDiffPercent)
+	for i, p := range rep.Pairs {
+		ok := "ok"
+		if !p.ok {
+			ok = "not ok"
+		}
+		fmt.Fprintf(&out, "%s %d - %s\n", ok, i+1, p.Name)
+		fmt.Fprintln(&out, "  ---")
+		fmt.Fprintf(&out, "  message: %s\n", strconv.Quote(p.summary()))
+		for _, d := range p.details() {
+			fmt.Fprintf(&out, "  %s: %s\n", d[0], strconv.Quote(d[1]))
+		}
+		fmt.Fprintln(&out, "  ...")
+	}
+	_, err := io.WriteString(w, out.String())
+	return err
+}
+
+// errCode returns the exit code of the error.
+func errCode(err error) int {
+	switch {
+	case errors.Is(err, pixmatch.ErrDimensionsDoNotMatch):
+		return pixmatch.ExitDimensionsNotEqual
+	case errors.Is(err, pixmatch.ErrImageIsEmpty):
+		return pixmatch.ExitEmptyImage
+	case errors.Is(err, pixmatch.ErrUnknownFormat),
+		errors.Is(err, pixmatch.ErrUnsupportedFormat),
+		errors.Is(err, image.ErrFormat):
+		return pixmatch.ExitUnknownFormat
+	case errors.Is(err, pixmatch.ErrInvalidPath),
+		errors.Is(err, pixmatch.ErrUnsupportedOption):
+		return pixmatch.ExitInvalidInput
+	case errors.As(err, new(*fs.PathError)):
+		return pixmatch.ExitFSFail
+	}
+	return pixmatch.ExitUnknown
+}
+
+// imageSize reads dimensions of the image from its header. Zero size is
+// returned if the image cannot be read.
+func imageSize(path string) (int, int) {
+	fp, err := os.Open(path)
+	if err != nil {
+		return 0, 0
+	}
+	defer fp.Close()
+	cfg, _, err := image.DecodeConfig(fp)
+	if err != nil {
+		return 0, 0
+	}
+	return cfg.Width, cfg.Height
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"image"
+	"io/fs"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/dknight/go-pixmatch"
+)
+
+// testPairs returns pairs of every status.
+func testPairs() []*pixmatch.PairResult {
+	return []*pixmatch.PairResult{
+		{Path: "passed.png", Expected: "e/passed.png", Actual: "a/passed.png",
+			Status: pixmatch.PairPassed, Width: 2, Height: 2,
+			Result: &pixmatch.Result{Total: 4}},
+		{Path: "failed.png", Expected: "e/failed.png", Actual: "a/failed.png",
+			Diff: "d/failed.png", Status: pixmatch.PairFailed, Width: 2,
+			Height: 2, Result: &pixmatch.Result{Diff: 3, AA: 1, Total: 4,
+				Exceeded: true}},
+		{Path: "missing.png", Expected: "e/missing.png",
+			Status: pixmatch.PairMissing},
+		{Path: "extra.png", Actual: "a/extra.png", Status: pixmatch.PairExtra},
+		{Path: "error.png", Expected: "e/error.png", Actual: "a/error.png",
+			Status: pixmatch.PairError,
+			Err:    pixmatch.ErrDimensionsDoNotMatch},
+		{Path: "recorded.png", Expected: "e/recorded.png",
+			Actual: "a/recorded.png", Status: pixmatch.PairRecorded},
+	}
+}
+
+func TestNewReport(t *testing.T) {
+	opts := pixmatch.NewOptions().SetMaxDiff(2)
+	rep := newReport(opts, testPairs(), pixmatch.ExitBatchFailed)
+	if rep.Passed || rep.Code != pixmatch.ExitBatchFailed {
+		t.Errorf("Expected failed report with %v got %v with %v",
+			pixmatch.ExitBatchFailed, rep.Passed, rep.Code)
+	}
+	if rep.MaxDiff != 2 || rep.MaxDiffPercent != -1 || rep.Metric != "yiq" {
+		t.Errorf("Expected 2, -1, yiq got %v, %v, %v", rep.MaxDiff,
+			rep.MaxDiffPercent, rep.Metric)
+	}
+
+	tests := []struct {
+		status string
+		code   int
+		ok     bool
+	}{
+		{"passed", pixmatch.ExitOk, true},
+		{"failed", pixmatch.ExitDiffExceeded, false},
+		{"missing", pixmatch.ExitMissingImage, false},
+		{"extra", pixmatch.ExitMissingImage, false},
+		{"error", pixmatch.ExitDimensionsNotEqual, false},
+		{"recorded", pixmatch.ExitOk, true},
+	}
+	if len(rep.Pairs) != len(tests) {
+		t.Fatalf("Expected %v got %v", len(tests), len(rep.Pairs))
+	}
+	for i, tt := range tests {
+		p := rep.Pairs[i]
+		if p.Status != tt.status || p.Code != tt.code || p.ok != tt.ok {
+			t.Errorf("Expected %v %v %v got %v %v %v", tt.status, tt.code,
+				tt.ok, p.Status, p.Code, p.ok)
+		}
+	}
+	if p := rep.Pairs[1]; p.Diff != 3 || p.AA != 1 || p.Percent != 75 {
+		t.Errorf("Expected 3, 1, 75 got %v, %v, %v", p.Diff, p.AA, p.Percent)
+	}
+
+	rep = newReport(nil, testPairs()[:1], pixmatch.ExitOk)
+	if !rep.Passed || rep.MaxDiff != -1 {
+		t.Errorf("Expected passed report without budget got %v, %v",
+			rep.Passed, rep.MaxDiff)
+	}
+}
+
+func TestReport_JSON(t *testing.T) {
+	var buf bytes.Buffer
+	rep := newReport(nil, testPairs(), pixmatch.ExitBatchFailed)
+	if err := rep.write(&buf, formatJSON); err != nil {
+		t.Fatal(err)
+	}
+	var got struct {
+		Code  int                      `json:"code"`
+		Pairs []map[string]interface{} `json:"pairs"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Code != pixmatch.ExitBatchFailed {
+		t.Errorf("Expected %v got %v", pixmatch.ExitBatchFailed, got.Code)
+	}
+
+	tests := []struct {
+		pair   int
+		fields []string
+	}{
+		{0, []string{"aa", "actual", "code", "diff", "expected", "height",
+			"name", "percent", "status", "width"}},
+		{1, []string{"aa", "actual", "code", "diff", "diffPath", "expected",
+			"height", "name", "percent", "status", "width"}},
+		{4, []string{"aa", "actual", "code", "diff", "error", "expected",
+			"height", "name", "percent", "status", "width"}},
+	}
+	for _, tt := range tests {
+		var fields []string
+		for k := range got.Pairs[tt.pair] {
+			fields = append(fields, k)
+		}
+		sort.Strings(fields)
+		if !reflect.DeepEqual(fields, tt.fields) {
+			t.Errorf("Expected %v got %v", tt.fields, fields)
+		}
+	}
+	if p := got.Pairs[1]; p["diffPath"] != "d/failed.png" ||
+		p["code"] != float64(pixmatch.ExitDiffExceeded) {
+		t.Errorf("Expected %v and %v got %v and %v", "d/failed.png",
+			pixmatch.ExitDiffExceeded, p["diffPath"], p["code"])
+	}
+}
+
+func TestReport_JUnit(t *testing.T) {
+	var buf bytes.Buffer
+	rep := newReport(nil, testPairs(), pixmatch.ExitBatchFailed)
+	if err := rep.write(&buf, formatJUnit); err != nil {
+		t.Fatal(err)
+	}
+	var got junitTestSuites
+	if err := xml.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	// Different, missing and extra images are failures, not errors.
+	if got.Tests != 6 || got.Failures != 3 || got.Errors != 1 {
+		t.Errorf("Expected 6 tests, 3 failures, 1 errors got %v, %v, %v",
+			got.Tests, got.Failures, got.Errors)
+	}
+	if len(got.Suites) != 1 || got.Suites[0].Failures != 3 ||
+		got.Suites[0].Errors != 1 {
+		t.Fatalf("Expected suite with 3 failures and 1 errors got %+v",
+			got.Suites)
+	}
+
+	tests := []struct {
+		failure, error bool
+	}{
+		{false, false},
+		{true, false},
+		{true, false},
+		{true, false},
+		{false, true},
+		{false, false},
+	}
+	for i, tt := range tests {
+		tc := got.Suites[0].TestCases[i]
+		if (tc.Failure != nil) != tt.failure || (tc.Error != nil) != tt.error {
+			t.Errorf("%v: expected failure %v, error %v got %v, %v", tc.Name,
+				tt.failure, tt.error, tc.Failure != nil, tc.Error != nil)
+		}
+	}
+	tc := got.Suites[0].TestCases[1]
+	if !strings.Contains(tc.SystemOut.Text, "[[ATTACHMENT|d/failed.png]]") {
+		t.Errorf("Expected attachment in %q", tc.SystemOut.Text)
+	}
+}
+
+func TestReport_TAP(t *testing.T) {
+	var buf bytes.Buffer
+	rep := newReport(nil, testPairs(), pixmatch.ExitBatchFailed)
+	if err := rep.write(&buf, formatTAP); err != nil {
+		t.Fatal(err)
+	}
+	var lines []string
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if strings.HasPrefix(line, "ok") || strings.HasPrefix(line, "not ok") {
+			lines = append(lines, line)
+		}
+	}
+	want := []string{
+		"ok 1 - passed.png",
+		"not ok 2 - failed.png",
+		"not ok 3 - missing.png",
+		"not ok 4 - extra.png",
+		"not ok 5 - error.png",
+		"ok 6 - recorded.png",
+	}
+	if !reflect.DeepEqual(lines, want) {
+		t.Errorf("Expected %v got %v", want, lines)
+	}
+	for _, s := range []string{"TAP version 13\n", "1..6\n",
+		"  message: \"3 different pixels (75.00%)\"\n",
+		"  diffPath: \"d/failed.png\"\n"} {
+		if !strings.Contains(buf.String(), s) {
+			t.Errorf("Expected %q in %q", s, buf.String())
+		}
+	}
+
+	if err := rep.write(&buf, "yaml"); err == nil {
+		t.Error("Expected error of invalid format")
+	}
+}
+
+func TestErrCode(t *testing.T) {
+	tests := []struct {
+		err  error
+		code int
+	}{
+		{pixmatch.ErrDimensionsDoNotMatch, pixmatch.ExitDimensionsNotEqual},
+		{pixmatch.ErrImageIsEmpty, pixmatch.ExitEmptyImage},
+		{pixmatch.ErrUnknownFormat, pixmatch.ExitUnknownFormat},
+		{pixmatch.ErrUnsupportedFormat, pixmatch.ExitUnknownFormat},
+		{image.ErrFormat, pixmatch.ExitUnknownFormat},
+		{pixmatch.ErrInvalidPath, pixmatch.ExitInvalidInput},
+		{pixmatch.ErrUnsupportedOption, pixmatch.ExitInvalidInput},
+		{&fs.PathError{Op: "open", Path: "x.png", Err: fs.ErrNotExist},
+			pixmatch.ExitFSFail},
+		{fmt.Errorf("wrapped: %w", pixmatch.ErrImageIsEmpty),
+			pixmatch.ExitEmptyImage},
+		{errors.New("unknown"), pixmatch.ExitUnknown},
+	}
+	for _, tt := range tests {
+		if code := errCode(tt.err); code != tt.code {
+			t.Errorf("%v: expected %v got %v", tt.err, tt.code, code)
+		}
+	}
+}
@@ -67,6 +67,26 @@ func (c Color) Blend(a float64) *Color {
 	return NewColor(uint32(r), uint32(g), uint32(b), c.A)
 }
 
+// rgb blends the color with its alpha channel and converts it to the opaque
+// color, which can be measured with the metric.
+func (c Color) rgb() RGB {
+	if c.A < 0xff {
+		c = *c.Blend(float64(c.A) / 0xff)
+	}
+	return RGB{float64(c.R), float64(c.G), float64(c.B)}
+}
+
+// rgb16 is like rgb, but for the color with 16-bit channels. Channels are
+// scaled to the 8-bit range without rounding.
+func (c Color) rgb16() RGB {
+	r, g, b := float64(c.R)/0x101, float64(c.G)/0x101, float64(c.B)/0x101
+	if c.A < 0xffff {
+		a := float64(c.A) / 0xffff
+		r, g, b = 0xff-r*a, 0xff-g*a, 0xff-b*a
+	}
+	return RGB{r, g, b}
+}
+
 // BlendToGray draws gray-scaled color with gray-scaled blending.
 func (c Color) BlendToGray(a float64) color.Color {
 	y := uint32(c.Y()) >> 8
@@ -77,6 +97,15 @@ func (c Color) BlendToGray(a float64) color.Color {
 	return color.RGBA{gray, gray, gray, 0xff}
 }
 
+// blendToGray16 is like BlendToGray, but keeps 16 bits of the gray.
+func (c Color) blendToGray16(a float64) color.Color {
+	gray := uint16(0xffff + (c.Y()-0xffff)*a)
+	if c.A == 0 {
+		gray = 0xffff
+	}
+	return color.RGBA64{gray, gray, gray, 0xffff}
+}
+
 // HexStringToColor converts hexadecimal string RRGGBBAA of color
 // representation to [image/color.RGBA]. Input string are case-insensitive.
 // Also strings can be prefixed with '0x' or '0X'.
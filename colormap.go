@@ -0,0 +1,110 @@
+package pixmatch
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+)
+
+// Colormap maps values in range [0, 1] to colors. Colors are evenly spaced
+// stops, values between them are interpolated linearly.
+type Colormap []color.RGBA
+
+// Built-in colormaps. Viridis and Inferno are perceptually uniform
+// colormaps from matplotlib: https://bids.github.io/colormap/
+var (
+	Viridis = Colormap{
+		{0x44, 0x01, 0x54, 0xff},
+		{0x47, 0x2d, 0x7b, 0xff},
+		{0x3b, 0x52, 0x8b, 0xff},
+		{0x2c, 0x72, 0x8e, 0xff},
+		{0x21, 0x91, 0x8c, 0xff},
+		{0x28, 0xae, 0x80, 0xff},
+		{0x5e, 0xc9, 0x62, 0xff},
+		{0xad, 0xdc, 0x30, 0xff},
+		{0xfd, 0xe7, 0x25, 0xff},
+	}
+
+	Inferno = Colormap{
+		{0x00, 0x00, 0x04, 0xff},
+		{0x1b, 0x0c, 0x41, 0xff},
+		{0x4a, 0x0c, 0x6b, 0xff},
+		{0x78, 0x1c, 0x6d, 0xff},
+		{0xa5, 0x2c, 0x60, 0xff},
+		{0xcf, 0x44, 0x46, 0xff},
+		{0xed, 0x69, 0x25, 0xff},
+		{0xfb, 0x9b, 0x06, 0xff},
+		{0xf7, 0xd1, 0x3d, 0xff},
+		{0xfc, 0xff, 0xa4, 0xff},
+	}
+
+	Grayscale = Colormap{
+		{0, 0, 0, 0xff},
+		{0xff, 0xff, 0xff, 0xff},
+	}
+)
+
+// Common constants for heatmaps.
+const (
+	// faintAlpha is the opacity of the under-threshold deltas.
+	faintAlpha = 0.35
+
+	// legendHeight is the height of the legend strip in pixels.
+	legendHeight = 10
+)
+
+// At returns the color of the value. Values out of range [0, 1] are clamped.
+func (cm Colormap) At(v float64) color.RGBA {
+	if len(cm) == 0 {
+		return color.RGBA{}
+	}
+	if len(cm) == 1 || v <= 0 || math.IsNaN(v) {
+		return cm[0]
+	}
+	if v >= 1 {
+		return cm[len(cm)-1]
+	}
+	pos := v * float64(len(cm)-1)
+	i := int(pos)
+	return mix(cm[i], cm[i+1], pos-float64(i))
+}
+
+// Legend draws the horizontal gradient of the colormap on the image's
+// rectangle r.
+func (cm Colormap) Legend(dst draw.Image, r image.Rectangle) {
+	for x := r.Min.X; x < r.Max.X; x++ {
+		v := 0.0
+		if r.Dx() > 1 {
+			v = float64(x-r.Min.X) / float64(r.Dx()-1)
+		}
+		c := cm.At(v)
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			dst.Set(x, y, c)
+		}
+	}
+}
+
+// withLegend returns a copy of the image with the legend strip of the
+// colormap at the bottom.
+func (cm Colormap) withLegend(m image.Image) *image.RGBA {
+	b := m.Bounds()
+	res := image.NewRGBA(image.Rect(b.Min.X, b.Min.Y, b.Max.X,
+		b.Max.Y+legendHeight))
+	draw.Draw(res, b, m, b.Min, draw.Src)
+	cm.Legend(res, image.Rect(b.Min.X, b.Max.Y, b.Max.X, b.Max.Y+legendHeight))
+	return res
+}
+
+// mix interpolates linearly between two colors, t in range [0, 1].
+func mix(c1, c2 color.RGBA, t float64) color.RGBA {
+	lerp := func(a, b uint8) uint8 {
+		return uint8(float64(a) + (float64(b)-float64(a))*t + 0.5)
+	}
+	return color.RGBA{
+		lerp(c1.R, c2.R),
+		lerp(c1.G, c2.G),
+		lerp(c1.B, c2.B),
+		lerp(c1.A, c2.A),
+	}
+}
@@ -0,0 +1,120 @@
+package pixmatch
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+func TestColormapAt(t *testing.T) {
+	cm := Colormap{{0, 0, 0, 0xff}, {0xff, 0x80, 0, 0xff}}
+	pairs := map[float64]color.RGBA{
+		-1:   {0, 0, 0, 0xff},
+		0:    {0, 0, 0, 0xff},
+		0.5:  {0x80, 0x40, 0, 0xff},
+		1:    {0xff, 0x80, 0, 0xff},
+		1.25: {0xff, 0x80, 0, 0xff},
+	}
+	for v, want := range pairs {
+		if res := cm.At(v); res != want {
+			t.Errorf("%v: expected %v got %v", v, want, res)
+		}
+	}
+
+	if res := (Colormap{}).At(0.5); res != (color.RGBA{}) {
+		t.Errorf("Expected %v got %v", color.RGBA{}, res)
+	}
+	if res := Viridis.At(1); res != Viridis[len(Viridis)-1] {
+		t.Errorf("Expected %v got %v", Viridis[len(Viridis)-1], res)
+	}
+}
+
+func TestColormapLegend(t *testing.T) {
+	m := image.NewRGBA(image.Rect(0, 0, 11, 2))
+	Grayscale.Legend(m, m.Bounds())
+	pairs := map[int]uint8{0: 0, 5: 0x80, 10: 0xff}
+	for x, want := range pairs {
+		if res := m.RGBAAt(x, 1).R; res != want {
+			t.Errorf("%v: expected %v got %v", x, want, res)
+		}
+	}
+}
+
+func TestCompare_Heatmap(t *testing.T) {
+	img1, _ := NewImageFromPath("./samples/form-a.png")
+	img2, _ := NewImageFromPath("./samples/form-b.png")
+
+	var buf bytes.Buffer
+	opts := NewOptions().SetColormap(Inferno).SetFaint(true).
+		SetLegend(true).SetOutput(&buf)
+	res, err := img1.CompareResult(img2, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Diff != 2909 {
+		t.Errorf("Expected %v got %v", 2909, res.Diff)
+	}
+	out, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := img1.Bounds().Dy() + legendHeight
+	if out.Bounds().Dy() != want {
+		t.Errorf("Expected %v got %v", want, out.Bounds().Dy())
+	}
+	r, g, b, _ := out.At(0, want-1).RGBA()
+	c := color.RGBA{uint8(r >> 8), uint8(g >> 8), uint8(b >> 8), 0xff}
+	if c != Inferno[0] {
+		t.Errorf("Expected %v got %v", Inferno[0], c)
+	}
+}
+
+func TestCompare_HeatmapThreshold(t *testing.T) {
+	// The YIQ delta of grays 100 and 127 is just above the threshold 0.1.
+	img1 := newUniformImage(4, 4, color.NRGBA{100, 100, 100, 0xff})
+	img2 := newUniformImage(4, 4, color.NRGBA{127, 127, 127, 0xff})
+
+	var buf bytes.Buffer
+	opts := NewOptions().SetColormap(Inferno).SetOutput(&buf)
+	res, err := img1.CompareResult(img2, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Diff != 16 {
+		t.Errorf("Expected %v got %v", 16, res.Diff)
+	}
+	out, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := color.RGBAModel.Convert(out.At(0, 0)).(color.RGBA)
+	want := Inferno.At(0.1)
+	near := func(v1, v2 uint8) bool {
+		return intAbs(int(v1)-int(v2)) <= 8
+	}
+	if !near(got.R, want.R) || !near(got.G, want.G) || !near(got.B, want.B) {
+		t.Errorf("Expected %v got %v", want, got)
+	}
+	brightness := func(c color.RGBA) int {
+		return int(c.R) + int(c.G) + int(c.B)
+	}
+	if brightness(got) < brightness(Inferno[0])+64 {
+		t.Errorf("Expected visible color got %v", got)
+	}
+}
+
+func TestSSIMMapColorize(t *testing.T) {
+	img, _ := NewImageFromPath("./samples/gray8-a.png")
+	m, err := img.SSIMMap(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := m.Colorize(Viridis)
+	r, g, b, _ := out.At(0, 0).RGBA()
+	c := color.RGBA{uint8(r >> 8), uint8(g >> 8), uint8(b >> 8), 0xff}
+	if c != Viridis[0] {
+		t.Errorf("Expected %v got %v", Viridis[0], c)
+	}
+}
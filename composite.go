@@ -0,0 +1,147 @@
+package pixmatch
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// Layout is the layout of the panels in the composite image.
+type Layout int
+
+const (
+	// LayoutNone renders no composite image, only the difference.
+	LayoutNone Layout = iota
+
+	// LayoutHorizontal places panels side by side from left to right.
+	LayoutHorizontal
+
+	// LayoutVertical places panels from top to bottom.
+	LayoutVertical
+)
+
+// Common constants for composite images.
+const (
+	// panelGap is the gap between panels in pixels.
+	panelGap = 4
+
+	// labelPadding is the padding around labels in pixels.
+	labelPadding = 3
+
+	// onionOpacity is the opacity of the second image in the overlay panel.
+	onionOpacity = 0.5
+)
+
+// Colors of the composite image.
+var (
+	compositeBackground = color.RGBA{0xff, 0xff, 0xff, 0xff}
+	labelBackground     = color.RGBA{0xee, 0xee, 0xee, 0xff}
+	labelColor          = color.RGBA{0x22, 0x22, 0x22, 0xff}
+)
+
+// Panel is the single labeled image of the composite image.
+type Panel struct {
+	// Label is the text above the image. Only latin letters, digits and
+	// some punctuation are supported. Empty labels are not rendered.
+	Label string
+
+	// Image is the image of the panel.
+	Image image.Image
+}
+
+// Composite draws panels in a single image according to the layout. If any
+// panel has the label, all panels get the label strip above the image.
+func Composite(layout Layout, panels ...Panel) *image.RGBA {
+	labeled := false
+	minWidth := 0
+	for i, p := range panels {
+		labeled = labeled || p.Label != ""
+		if i == 0 || p.Image.Bounds().Dx() < minWidth {
+			minWidth = p.Image.Bounds().Dx()
+		}
+	}
+	scale := intMax(1, minWidth/200)
+	strip := 0
+	if labeled {
+		strip = glyphHeight*scale + 2*labelPadding
+	}
+
+	// Calculate the size of the canvas.
+	w, h := 0, 0
+	for i, p := range panels {
+		size := p.Image.Bounds().Size().Add(image.Pt(0, strip))
+		gap := 0
+		if i > 0 {
+			gap = panelGap
+		}
+		if layout == LayoutVertical {
+			w = intMax(w, size.X)
+			h += size.Y + gap
+		} else {
+			w += size.X + gap
+			h = intMax(h, size.Y)
+		}
+	}
+
+	res := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(res, res.Bounds(), &image.Uniform{compositeBackground},
+		image.Point{}, draw.Src)
+
+	pt := image.Point{}
+	for _, p := range panels {
+		b := p.Image.Bounds()
+		if labeled {
+			r := image.Rect(pt.X, pt.Y, pt.X+b.Dx(), pt.Y+strip)
+			draw.Draw(res, r, &image.Uniform{labelBackground}, image.Point{},
+				draw.Src)
+			drawText(res, pt.Add(image.Pt(labelPadding, labelPadding)),
+				p.Label, labelColor, scale)
+		}
+		r := image.Rectangle{pt, pt.Add(b.Size())}.Add(image.Pt(0, strip))
+		draw.Draw(res, r, p.Image, b.Min, draw.Src)
+		if layout == LayoutVertical {
+			pt.Y += b.Dy() + strip + panelGap
+		} else {
+			pt.X += b.Dx() + panelGap
+		}
+	}
+	return res
+}
+
+// Overlay blends the second image over the first one with the given opacity
+// in range [0, 1], also known as onion skin. Top left corners of the images
+// are aligned.
+func Overlay(img1, img2 image.Image, opacity float64) *image.RGBA {
+	b1, b2 := img1.Bounds(), img2.Bounds()
+	r2 := image.Rectangle{b1.Min, b1.Min.Add(b2.Size())}
+	res := image.NewRGBA(b1.Union(r2))
+	draw.Draw(res, res.Bounds(), &image.Uniform{compositeBackground},
+		image.Point{}, draw.Src)
+	draw.Draw(res, b1, img1, b1.Min, draw.Over)
+	mask := &image.Uniform{color.Alpha{uint8(opacity*0xff + 0.5)}}
+	draw.DrawMask(res, r2, img2, b2.Min, mask, image.Point{}, draw.Over)
+	return res
+}
+
+// composite renders the composite image of the expected image, the actual
+// image img2 and the difference according to the options. Images m1 and m2
+// are blended in the overlay panel as they were compared: resampled, placed
+// on the common canvas or aligned.
+func (img *Image) composite(img2, diff *Image, m1, m2 image.Image,
+	opts *Options) *Image {
+	panels := []Panel{
+		{"Expected", img.Image},
+		{"Actual", img2.Image},
+		{"Diff", diff.Image},
+	}
+	if opts.Onion {
+		panels = append(panels,
+			Panel{"Overlay", Overlay(m1, m2, onionOpacity)})
+	}
+	if !opts.Labels {
+		for i := range panels {
+			panels[i].Label = ""
+		}
+	}
+	return NewImageFromImage(Composite(opts.Composite, panels...), img.Format)
+}
@@ -0,0 +1,113 @@
+package pixmatch
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+func TestComposite(t *testing.T) {
+	red := newUniformImage(10, 20, color.NRGBA{0xff, 0, 0, 0xff})
+	blue := newUniformImage(30, 5, color.NRGBA{0, 0, 0xff, 0xff})
+	strip := glyphHeight + 2*labelPadding
+
+	h := Composite(LayoutHorizontal, Panel{"", red}, Panel{"", blue})
+	want := image.Rect(0, 0, 10+panelGap+30, 20)
+	if !h.Bounds().Eq(want) {
+		t.Errorf("Expected %v got %v", want, h.Bounds())
+	}
+	if h.RGBAAt(10+panelGap, 0) != (color.RGBA{0, 0, 0xff, 0xff}) {
+		t.Errorf("Expected blue panel got %v", h.RGBAAt(10+panelGap, 0))
+	}
+
+	v := Composite(LayoutVertical, Panel{"A", red}, Panel{"B", blue})
+	want = image.Rect(0, 0, 30, 20+5+panelGap+2*strip)
+	if !v.Bounds().Eq(want) {
+		t.Errorf("Expected %v got %v", want, v.Bounds())
+	}
+	if v.RGBAAt(0, 0) != labelBackground {
+		t.Errorf("Expected label background got %v", v.RGBAAt(0, 0))
+	}
+	if v.RGBAAt(0, strip) != (color.RGBA{0xff, 0, 0, 0xff}) {
+		t.Errorf("Expected red panel got %v", v.RGBAAt(0, strip))
+	}
+}
+
+func TestOverlay(t *testing.T) {
+	black := newUniformImage(2, 2, color.NRGBA{0, 0, 0, 0xff})
+	white := newUniformImage(2, 2, color.NRGBA{0xff, 0xff, 0xff, 0xff})
+	res := Overlay(black, white, 0.5)
+	c := res.RGBAAt(1, 1)
+	if c.R < 0x7e || c.R > 0x81 {
+		t.Errorf("Expected gray color got %v", c)
+	}
+}
+
+func TestDrawText(t *testing.T) {
+	if w := textWidth("Diff", 2); w != (4*(glyphWidth+1)-1)*2 {
+		t.Errorf("Expected %v got %v", (4*(glyphWidth+1)-1)*2, w)
+	}
+	if w := textWidth("", 1); w != 0 {
+		t.Errorf("Expected %v got %v", 0, w)
+	}
+
+	m := image.NewRGBA(image.Rect(0, 0, 20, glyphHeight))
+	c := color.RGBA{0, 0, 0, 0xff}
+	drawText(m, image.Point{}, "i~", c, 1)
+	// Top row of 'I' and '?' glyphs.
+	if m.RGBAAt(1, 0) != c || m.RGBAAt(0, 0) == c {
+		t.Error("Invalid glyph of 'I'")
+	}
+	if m.RGBAAt(glyphWidth+2, 0) != c {
+		t.Error("Unknown characters should be rendered as '?'")
+	}
+}
+
+func TestCompare_Composite(t *testing.T) {
+	img1, _ := NewImageFromPath("./samples/gray8-a.png")
+	img2, _ := NewImageFromPath("./samples/gray8-b.png")
+	var buf bytes.Buffer
+	opts := NewOptions().SetComposite(LayoutHorizontal).SetOnion(true).
+		SetOutput(&buf)
+	if _, err := img1.Compare(img2, opts); err != nil {
+		t.Fatal(err)
+	}
+	out, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := image.Rect(0, 0, 4*16+3*panelGap, 16)
+	if !out.Bounds().Eq(want) {
+		t.Errorf("Expected %v got %v", want, out.Bounds())
+	}
+}
+
+func TestCompare_CompositeAligned(t *testing.T) {
+	imageA, _ := NewImageFromPath("./samples/form-a.png")
+	imageB := translated(imageA, image.Pt(2, 1))
+	var buf bytes.Buffer
+	opts := NewOptions().SetAlignRadius(5).SetIncludeAA(true).
+		SetComposite(LayoutHorizontal).SetKeepEmptyDiff(true).SetOutput(&buf)
+	if _, err := imageA.Compare(imageB, opts); err != nil {
+		t.Fatal(err)
+	}
+	out, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The actual panel shows the second image as it is given, not aligned.
+	b := imageB.Bounds()
+	x0 := b.Dx() + panelGap
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			want := color.RGBAModel.Convert(imageB.At(x, y))
+			if got := out.At(x0+x, y); got != want {
+				t.Fatalf("Expected %v got %v at %v", want, got,
+					image.Pt(x, y))
+			}
+		}
+	}
+}
@@ -2,7 +2,8 @@
 // [Pixelmatch.js], but rewritten in idiomatic Go to speed up images
 // comparison.
 //
-// Go pixmatch has support for PNG, GIF and JPEG formats. This tool also
+// Go pixmatch has support for PNG, GIF, JPEG, BMP, TIFF and Netpbm (PBM, PGM,
+// PPM) formats, the format is detected by the content. This tool also
 // accurately detects anti-aliasing and may (or may not) count it as a
 // difference.
 // [Pixelmatch.js]: https://github.com/mapbox/pixelmatch
@@ -22,11 +22,27 @@ var (
 	// unknown.
 	ErrUnknownFormat = errors.New("unknown image format")
 
+	// ErrUnsupportedFormat occurs when the image format is recognized, but
+	// no decoder is available for it.
+	ErrUnsupportedFormat = errors.New("image format is recognized but not supported")
+
 	// ErrInvalidColorFormat occurs when user enter invalid color format.
 	ErrInvalidColorFormat = errors.New("invalid color format")
 
 	// ErrMissingImage occurs when one or both images are missing.
 	ErrMissingImage = errors.New("one or both images are missing")
+
+	// ErrInvalidPath occurs when the relative path points outside of the
+	// directory.
+	ErrInvalidPath = errors.New("path is outside of the directory")
+
+	// ErrInvalidConnectivity occurs when connectivity of the clusters is
+	// neither 4 nor 8.
+	ErrInvalidConnectivity = errors.New("connectivity must be 4 or 8")
+
+	// ErrUnsupportedOption occurs when the option cannot be applied by the
+	// stream comparison.
+	ErrUnsupportedOption = errors.New("option is not supported by stream comparison")
 )
 
 // Exit codes that are not defined in the [BSD and Linux specifications].
@@ -55,6 +71,13 @@ const (
 	// ExitUnknownFormat if format of the image is not supported.
 	ExitUnknownFormat = 105
 
+	// ExitDiffExceeded if the number of different pixels exceeds the budget.
+	ExitDiffExceeded = 106
+
+	// ExitBatchFailed if any pair of images of the batch is different,
+	// missing or cannot be compared.
+	ExitBatchFailed = 107
+
 	// ExitUnknown all other failings.
 	ExitUnknown = 199
 )
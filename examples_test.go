@@ -1,6 +1,7 @@
 package pixmatch
 
 import (
+	"context"
 	"fmt"
 	"image/color"
 	"log"
@@ -97,3 +98,26 @@ func ExampleImage_Size() {
 	fmt.Println(samplesult)
 	// Output: 51200
 }
+
+func ExampleImage_CompareResult() {
+	img1, _ := NewImageFromPath("./samples/form-a.png")
+	img2, _ := NewImageFromPath("./samples/form-b.png")
+	res, _ := img1.CompareResult(img2, nil)
+
+	fmt.Println(res.Diff, res.Total)
+	fmt.Printf("%.2f%%\n", res.Percent())
+	// Output:
+	// 2909 51200
+	// 5.68%
+}
+
+func ExampleImage_CompareContext() {
+	img1, _ := NewImageFromPath("./samples/form-a.png")
+	img2, _ := NewImageFromPath("./samples/form-b.png")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := img1.CompareContext(ctx, img2, NewOptions())
+	fmt.Println(err)
+	// Output: context canceled
+}
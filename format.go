@@ -0,0 +1,89 @@
+package pixmatch
+
+import (
+	"bytes"
+	"io"
+
+	// WebP has no decoder in the standard library.
+	_ "golang.org/x/image/webp"
+)
+
+// sniffLen is the number of bytes enough to detect any supported format.
+const sniffLen = 12
+
+// dataReader reads the data that is already in memory. It has Peek, so
+// image.Decode passes it to the decoders as is, and the TIFF decoder reads it
+// with io.ReaderAt instead of buffering the data up to the offsets from the
+// header.
+type dataReader struct {
+	*bytes.Reader
+	data []byte
+}
+
+func newDataReader(data []byte) *dataReader {
+	return &dataReader{bytes.NewReader(data), data}
+}
+
+// Peek returns the next n bytes without advancing the reader.
+func (r *dataReader) Peek(n int) ([]byte, error) {
+	off := len(r.data) - r.Len()
+	if off+n > len(r.data) {
+		return r.data[off:], io.EOF
+	}
+	return r.data[off : off+n], nil
+}
+
+// maxDensity is the maximum number of pixels per byte of data for the
+// formats whose decoders allocate the image before reading the pixels. BMP
+// has 8 bits per pixel at least, and TIFF has 1 bit per pixel compressed by
+// LZW with codes of 12 bits at most.
+var maxDensity = map[string]int{
+	FormatBMP:  1,
+	FormatTIFF: 8 << 12,
+}
+
+// magics maps the format to the magic bytes the data starts with. Question
+// mark matches any byte.
+var magics = []struct {
+	format string
+	magic  string
+}{
+	{FormatPNG, "\x89PNG\r\n\x1a\n"},
+	{FormatGIF, "GIF87a"},
+	{FormatGIF, "GIF89a"},
+	{FormatJPEG, "\xff\xd8"},
+	{FormatBMP, "BM????\x00\x00\x00\x00"},
+	{FormatTIFF, "II*\x00"},
+	{FormatTIFF, "MM\x00*"},
+	{FormatWebP, "RIFF????WEBP"},
+	{FormatPBM, "P1"},
+	{FormatPBM, "P4"},
+	{FormatPGM, "P2"},
+	{FormatPGM, "P5"},
+	{FormatPPM, "P3"},
+	{FormatPPM, "P6"},
+}
+
+// DetectFormat detects the image format by the magic bytes in the beginning
+// of the data. Empty string is returned if the format is unknown.
+func DetectFormat(header []byte) string {
+	for _, m := range magics {
+		if matchMagic(m.magic, header) {
+			return m.format
+		}
+	}
+	return ""
+}
+
+// matchMagic reports whether the data starts with the magic.
+func matchMagic(magic string, data []byte) bool {
+	if len(data) < len(magic) {
+		return false
+	}
+	for i, c := range []byte(magic) {
+		if c != '?' && c != data[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,296 @@
+package pixmatch
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// roundTrip saves the image in the format and loads it back.
+func roundTrip(t *testing.T, m image.Image, format string) *Image {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := NewImageFromImage(m, format).Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	img := NewImage(0, 0, "")
+	if err := img.Load(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if img.Format != format {
+		t.Errorf("Expected %v got %v", format, img.Format)
+	}
+	return img
+}
+
+// sameColors reports whether two images have equal colors.
+func sameColors(m1, m2 image.Image) bool {
+	if !m1.Bounds().Eq(m2.Bounds()) {
+		return false
+	}
+	b := m1.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c1 := color.NRGBA64Model.Convert(m1.At(x, y))
+			c2 := color.NRGBA64Model.Convert(m2.At(x, y))
+			if c1 != c2 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// testPattern creates an image with the gradient and semi-transparent
+// pixels.
+func testPattern(w, h int) *image.NRGBA {
+	m := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			m.SetNRGBA(x, y, color.NRGBA{
+				uint8(x * 0xff / w), uint8(y * 0xff / h), 0x80,
+				uint8(0xff - x*y),
+			})
+		}
+	}
+	return m
+}
+
+func TestDetectFormat(t *testing.T) {
+	headers := map[string]string{
+		"\x89PNG\r\n\x1a\n\x00\x00\x00\x0d":  FormatPNG,
+		"GIF89a\x10\x00":                     FormatGIF,
+		"\xff\xd8\xff\xe0":                   FormatJPEG,
+		"BM\x36\x00\x00\x00\x00\x00\x00\x00": FormatBMP,
+		"II*\x00\x08\x00\x00\x00":            FormatTIFF,
+		"MM\x00*\x00\x00\x00\x08":            FormatTIFF,
+		"RIFF\x24\x00\x00\x00WEBPVP8L":       FormatWebP,
+		"P4\n8 8\n":                          FormatPBM,
+		"P2 # gray":                          FormatPGM,
+		"P6\n1 1 255\n":                      FormatPPM,
+		"RIFF\x24\x00\x00\x00WAVE":           "",
+		"P":                                  "",
+		"":                                   "",
+	}
+	for header, want := range headers {
+		if got := DetectFormat([]byte(header)); got != want {
+			t.Errorf("Expected %q got %q", want, got)
+		}
+	}
+}
+
+func TestNewFromPath_DetectFormat(t *testing.T) {
+	// Extension does not matter.
+	path := filepath.Join(t.TempDir(), "image.png")
+	fp, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := NewImageFromImage(testPattern(4, 4), FormatBMP).Save(fp); err != nil {
+		t.Fatal(err)
+	}
+	fp.Close()
+
+	img, err := NewImageFromPath(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Format != FormatBMP {
+		t.Errorf("Expected %v got %v", FormatBMP, img.Format)
+	}
+}
+
+func TestImageLoad_WebP(t *testing.T) {
+	img1, err := NewImageFromPath("./samples/pink.webp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img1.Format != FormatWebP {
+		t.Errorf("Expected %v got %v", FormatWebP, img1.Format)
+	}
+	// Lossless WebP is the same as PNG.
+	img2, _ := NewImageFromPath("./samples/pink.png")
+	diff, err := img1.Compare(img2, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff != 0 {
+		t.Errorf("Expected %v got %v", 0, diff)
+	}
+	var buf bytes.Buffer
+	if err := img1.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if DetectFormat(buf.Bytes()) != FormatPNG {
+		t.Errorf("Expected %v got %v", FormatPNG,
+			DetectFormat(buf.Bytes()))
+	}
+
+	data := "RIFF\x1a\x00\x00\x00WEBPVP8L\x0d\x00\x00\x00\x2f"
+	err = NewImage(0, 0, "").Load(bytes.NewBufferString(data))
+	if err == nil {
+		t.Error("Expected error got nil")
+	}
+}
+
+func TestBMP_RoundTrip(t *testing.T) {
+	// BMP has no alpha channel.
+	m := testPattern(7, 5)
+	img := roundTrip(t, m, FormatBMP)
+	for i := 3; i < len(m.Pix); i += 4 {
+		m.Pix[i] = 0xff
+	}
+	if !sameColors(m, img) {
+		t.Error("Colors of BMP do not match")
+	}
+
+	opaque := image.NewRGBA(image.Rect(0, 0, 3, 2))
+	opaque.Set(1, 1, color.RGBA{0x10, 0x20, 0x30, 0xff})
+	opaque.Set(2, 0, color.RGBA{0xff, 0, 0, 0xff})
+	for i := 3; i < len(opaque.Pix); i += 4 {
+		opaque.Pix[i] = 0xff
+	}
+	var buf bytes.Buffer
+	if err := NewImageFromImage(opaque, FormatBMP).Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if bpp := buf.Bytes()[28]; bpp != 24 {
+		t.Errorf("Expected %v got %v", 24, bpp)
+	}
+	img = roundTrip(t, opaque, FormatBMP)
+	if !sameColors(opaque, img) {
+		t.Error("Colors of opaque BMP do not match")
+	}
+
+	pal := image.NewPaletted(image.Rect(0, 0, 2, 2),
+		color.Palette{color.Black, color.White})
+	pal.SetColorIndex(0, 0, 1)
+	pal.SetColorIndex(1, 1, 1)
+	if img := roundTrip(t, pal, FormatBMP); !sameColors(pal, img) {
+		t.Error("Colors of paletted BMP do not match")
+	}
+}
+
+func TestTIFF_RoundTrip(t *testing.T) {
+	m := testPattern(9, 6)
+	img := roundTrip(t, m, FormatTIFF)
+	if !sameColors(m, img) {
+		t.Error("Colors of 8-bit TIFF do not match")
+	}
+
+	m16 := image.NewNRGBA64(image.Rect(0, 0, 3, 3))
+	m16.SetNRGBA64(1, 2, color.NRGBA64{0x1234, 0xabcd, 0x0101, 0x8000})
+	img = roundTrip(t, m16, FormatTIFF)
+	if !sameColors(m16, img) {
+		t.Error("Colors of 16-bit TIFF do not match")
+	}
+}
+
+// bmpHeader creates the header of 24-bit BMP without pixel data.
+func bmpHeader(w, h int32) []byte {
+	le := binary.LittleEndian
+	data := []byte("BM\x00\x00\x00\x00\x00\x00\x00\x00\x36\x00\x00\x00")
+	data = le.AppendUint32(data, 40)
+	data = le.AppendUint32(data, uint32(w))
+	data = le.AppendUint32(data, uint32(h))
+	data = le.AppendUint16(data, 1)
+	data = le.AppendUint16(data, 24)
+	return append(data, make([]byte, 24)...)
+}
+
+// tiffGray creates big endian 8-bit grayscale TIFF with single
+// uncompressed strip.
+func tiffGray(w, h uint32, strip []byte) []byte {
+	be := binary.BigEndian
+	tags := [][2]uint32{
+		{256, w},                  // ImageWidth
+		{257, h},                  // ImageLength
+		{258, 8},                  // BitsPerSample
+		{259, 1},                  // Compression
+		{262, 1},                  // PhotometricInterpretation
+		{273, 0},                  // StripOffsets
+		{278, h},                  // RowsPerStrip
+		{279, uint32(len(strip))}, // StripByteCounts
+	}
+	data := []byte("MM\x00*\x00\x00\x00\x08")
+	data = be.AppendUint16(data, uint16(len(tags)))
+	offset := len(data) + len(tags)*12 + 4
+	for _, tag := range tags {
+		if tag[0] == 273 {
+			tag[1] = uint32(offset)
+		}
+		data = be.AppendUint16(data, uint16(tag[0]))
+		data = be.AppendUint16(data, 4)
+		data = be.AppendUint32(data, 1)
+		data = be.AppendUint32(data, tag[1])
+	}
+	data = be.AppendUint32(data, 0)
+	return append(data, strip...)
+}
+
+func TestImageLoad_Valid(t *testing.T) {
+	want := []byte{10, 20, 30, 40, 10, 10, 10, 10}
+	img := NewImage(0, 0, "")
+	if err := img.Load(bytes.NewReader(tiffGray(4, 2, want))); err != nil {
+		t.Fatal(err)
+	}
+	gray := &image.Gray{Pix: want, Stride: 4, Rect: image.Rect(0, 0, 4, 2)}
+	if !sameColors(gray, img) {
+		t.Errorf("Expected %v got %v", gray, img.Image)
+	}
+
+	data := append(bmpHeader(2, 1), make([]byte, 8)...)
+	if err := img.Load(bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 2 || b.Dy() != 1 {
+		t.Errorf("Expected %v got %v", image.Rect(0, 0, 2, 1), b)
+	}
+}
+
+func TestImageLoad_Malformed(t *testing.T) {
+	inputs := map[string][]byte{
+		"bmp huge":     bmpHeader(0x7fffffff, 0x7fffffff),
+		"bmp negative": bmpHeader(-0x80000000, 1),
+		"bmp large":    append(bmpHeader(16384, 16384), 0, 0, 0),
+		// 1-bit BMP is not supported.
+		"bmp 1-bit": {
+			'B', 'M', 70, 0, 0, 0, 0, 0, 0, 0, 62, 0, 0, 0,
+			40, 0, 0, 0, 2, 0, 0, 0, 2, 0, 0, 0, 1, 0, 1, 0,
+			0, 0, 0, 0, 8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+			2, 0, 0, 0, 0, 0, 0, 0,
+			0, 0, 0, 0, 0xff, 0xff, 0xff, 0,
+			0x40, 0, 0, 0,
+			0x80, 0, 0, 0,
+		},
+		"bmp header":   []byte("BM\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00"),
+		"tiff huge":    tiffGray(0x7fffffff, 0x7fffffff, make([]byte, 8)),
+		"tiff large":   tiffGray(16384, 16384, make([]byte, 8)),
+		"tiff short":   tiffGray(4, 2, make([]byte, 4)),
+		"tiff header":  []byte("II*\x00\xff\xff\xff\xff"),
+		"netpbm huge":  []byte("P5 65536 65536 255\n\x00"),
+		"netpbm short": []byte("P6 4 4 255\n" + strings.Repeat("\x00", 47)),
+	}
+	for _, format := range []string{FormatPNG, FormatGIF, FormatJPEG,
+		FormatBMP, FormatTIFF, FormatPPM} {
+		var buf bytes.Buffer
+		m := NewImageFromImage(testPattern(16, 16), format)
+		if err := m.Save(&buf); err != nil {
+			t.Fatal(err)
+		}
+		data := buf.Bytes()
+		inputs[format+" truncated"] = data[:len(data)/2]
+		inputs[format+" magic"] = data[:sniffLen]
+	}
+	for name, data := range inputs {
+		err := NewImage(0, 0, "").Load(bytes.NewReader(data))
+		if err == nil {
+			t.Errorf("%v should not be loaded", name)
+		}
+	}
+}
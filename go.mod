@@ -1,3 +1,5 @@
 module github.com/dknight/go-pixmatch
 
 go 1.19
+
+require golang.org/x/image v0.18.0
@@ -1,7 +1,7 @@
 package pixmatch
 
 // I really don't want to overload code with generics, conversions,
-// or ever 3rd-party dependencies. So let just be these 2 simple functions.
+// or ever 3rd-party dependencies. So let just be these simple functions.
 
 // intMin returns the minimum int of 2 numbers.
 func intMin(x, y int) int {
@@ -18,3 +18,21 @@ func intMax(x, y int) int {
 	}
 	return y
 }
+
+// intAbs returns the absolute value of int number.
+func intAbs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// maxPixels is the maximum number of pixels of decoded images. It keeps
+// corrupted headers from allocating huge buffers.
+const maxPixels = 1 << 28
+
+// validSize reports whether dimensions are positive and the number of
+// pixels does not exceed maxPixels.
+func validSize(w, h int) bool {
+	return w > 0 && h > 0 && w <= maxPixels/h
+}
@@ -2,17 +2,22 @@ package pixmatch
 
 import (
 	"bytes"
+	"context"
 	"image"
 	"image/color"
+	"image/draw"
 	"image/gif"
 	"image/jpeg"
 	"image/png"
 	"io"
 	"math"
 	"os"
-	"path/filepath"
-	"reflect"
+	"runtime"
 	"sync"
+	"sync/atomic"
+
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/tiff"
 )
 
 // Common constants for pixmatch package.
@@ -22,12 +27,21 @@ const (
 	// Read more about YIQ NTSC https://en.wikipedia.org/wiki/YIQ
 	YIQDeltaMax = 35215
 
+	// bandHeight is the number of rows compared by a worker at once.
+	bandHeight = 8
+
 	// DefaultFormat is used if format is not specified.
 	DefaultFormat = FormatPNG
 
 	FormatPNG  = "png"
 	FormatGIF  = "gif"
 	FormatJPEG = "jpeg"
+	FormatBMP  = "bmp"
+	FormatTIFF = "tiff"
+	FormatWebP = "webp"
+	FormatPBM  = "pbm"
+	FormatPGM  = "pgm"
+	FormatPPM  = "ppm"
 )
 
 // Image represents the image structure.
@@ -38,7 +52,8 @@ type Image struct {
 	// Format as a string like (PNG, JEPG, GIF).
 	Format string
 
-	// PixData contains data for colors as uint32 numbers.
+	// PixData contains the channels of the normalized image as uint32
+	// numbers, four values per pixel: R, G, B and A.
 	PixData []uint32
 
 	// BPC is the number of bytes per color.
@@ -59,20 +74,20 @@ func NewImage(w, h int, format string) *Image {
 	}
 }
 
+// NewImageFromImage creates a new image instance from the [image.Image].
+func NewImageFromImage(m image.Image, format string) *Image {
+	img := NewImage(0, 0, format)
+	img.Image = m
+	img.cache()
+	return img
+}
+
 // NewImageFromPath creates a new image instance from the file system path.
+// The format is detected from the content of the file, not the extension.
+// Only the first frame of animated GIFs is loaded, use
+// [NewAnimationFromPath] to load all frames.
 func NewImageFromPath(path string) (*Image, error) {
-	ext := filepath.Ext(path)
-	format := FormatPNG
-	switch ext {
-	case ".png":
-		format = FormatPNG
-	case ".gif":
-		format = FormatGIF
-	case ".jpeg", ".jpg":
-		format = FormatJPEG
-	}
-
-	img := NewImage(0, 0, format)
+	img := NewImage(0, 0, DefaultFormat)
 	img.Path = path
 
 	fp, err := os.Open(img.Path)
@@ -86,16 +101,38 @@ func NewImageFromPath(path string) (*Image, error) {
 	return img, nil
 }
 
-// Load reads data from the reader.
+// Load reads data from the reader. The header is decoded first, so images
+// with too many pixels are rejected before the pixels are allocated.
 func (img *Image) Load(rd io.Reader) (err error) {
-	img.Image, img.Format, err = image.Decode(rd)
+	data, err := io.ReadAll(rd)
+	if err != nil {
+		return err
+	}
+	cfg, format, err := image.DecodeConfig(newDataReader(data))
+	if err != nil {
+		return err
+	}
+	w, h := cfg.Width, cfg.Height
+	if w < 0 || h < 0 || w > 0 && h > 0 && !validSize(w, h) {
+		return ErrCorruptedImage
+	}
+	if d := maxDensity[format]; d > 0 && w*h/d > len(data) {
+		return ErrCorruptedImage
+	}
+	img.Image, img.Format, err = image.Decode(newDataReader(data))
 	if err != nil {
 		return err
 	}
-	// Cache pixel data because Uint32() is very expensive.
+	img.cache()
+	return
+}
+
+// cache normalizes the image and caches pixel data because Uint32() is very
+// expensive.
+func (img *Image) cache() {
+	img.Image = Normalize(img.Image)
 	img.PixData = img.Uint32()
 	img.BPC = img.BytesPerColor()
-	return
 }
 
 // Size gives the total size of the image in pixels.
@@ -106,85 +143,377 @@ func (img *Image) Size() int {
 // Compare returns the number of different pixels between two comparable
 // images. Zero is returned if no difference found.Returns negative values
 // if something went wrong but in this case error also returned.
-//
-// Looks like process row of the pixel in a single goroutine is the most
-// performant way to do this, but I can mistake here.
 func (img *Image) Compare(img2 *Image, opts *Options) (int, error) {
+	return img.CompareContext(context.Background(), img2, opts)
+}
+
+// CompareContext compares two images like [Image.Compare] does. The
+// comparison stops when the context is done and the error of the context is
+// returned.
+func (img *Image) CompareContext(ctx context.Context, img2 *Image,
+	opts *Options) (int, error) {
+	res, err := img.CompareResultContext(ctx, img2, opts)
+	if err != nil {
+		return -1, err
+	}
+	return res.Diff, nil
+}
+
+// CompareResult compares two images like [Image.Compare] does, but returns
+// the detailed result of the comparison.
+func (img *Image) CompareResult(img2 *Image, opts *Options) (*Result, error) {
+	return img.CompareResultContext(context.Background(), img2, opts)
+}
+
+// CompareResultContext is like [Image.CompareResult], but stops when the
+// context is done and returns the error of the context.
+func (img *Image) CompareResultContext(ctx context.Context, img2 *Image,
+	opts *Options) (*Result, error) {
 	if opts == nil {
 		opts = NewOptions()
 	}
+	res, output, err := img.compare(ctx, img2, opts, opts.Output != nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// If no output given or there is no difference do not create diff file.
+	if output != nil {
+		err := output.Save(opts.Output)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// compare compares two images and renders the difference if render is true.
+// Output is nil if the images are identical or the comparison stopped
+// because the budget of differences is exceeded.
+//
+// Bands of rows are compared concurrently by opts.Workers goroutines.
+func (img *Image) compare(ctx context.Context, img2 *Image, opts *Options,
+	render bool) (*Result, *Image, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
 
 	// If empty images return error.
 	if img.Empty() || img2.Empty() {
-		return -1, ErrImageIsEmpty
+		return nil, nil, ErrImageIsEmpty
 	}
+	// Composite image shows images as they are given.
+	expected, actual := img, img2
 
-	// If dimensions do not match return error.
+	// Resample the second image to the dimensions of the first one.
+	if !img.DimensionsEqual(img2) && opts.Resample != ResampleNone {
+		img2 = img2.Resize(img.Bounds().Dx(), img.Bounds().Dy(),
+			opts.Resample)
+	}
+
+	// If dimensions do not match, return error or place images on the
+	// common canvas according to the size policy.
+	overlap := img.Bounds()
 	if !img.DimensionsEqual(img2) {
-		return -1, ErrDimensionsDoNotMatch
+		if opts.SizePolicy == SizeStrict {
+			return nil, nil, ErrDimensionsDoNotMatch
+		}
+		img, img2, overlap = img.place(img2, opts)
 	}
+	extra := !overlap.Eq(img.Bounds())
+
+	if opts.Connectivity != 0 && opts.Connectivity != 4 &&
+		opts.Connectivity != 8 {
+		return nil, nil, ErrInvalidConnectivity
+	}
+
+	res := &Result{}
+	skipping := len(opts.Ignore) > 0 || len(opts.Include) > 0
 
 	// If bytes are the same just return nothing to compare more.
 	if img.Identical(img2) {
-		return 0, nil
+		res.Total = img.Size()
+		if skipping {
+			res.Ignored = img.countSkipped(opts)
+			res.Total -= res.Ignored
+		}
+		if opts.Progress != nil {
+			opts.Progress(img.Bounds().Dy(), img.Bounds().Dy())
+		}
+		return res, nil, nil
+	}
+
+	// Shift the second image back if it is translated.
+	if opts.AlignRadius > 0 {
+		off, err := img.Align(img2, opts.AlignRadius)
+		if err != nil {
+			return nil, nil, err
+		}
+		res.Offset = off
+		if off != (image.Point{}) {
+			img2 = img.shift(img2, off, opts.PadColor)
+			overlap = overlap.Intersect(img.Bounds().Sub(off))
+			extra = true
+		}
+	}
+
+	metric := opts.Metric
+	if metric == nil {
+		metric = YIQ{}
+	}
+	maxDelta := metric.Limit(opts.Threshold)
+	deltaOf := img.MetricDelta
+	if opts.HighPrecision {
+		deltaOf = img.preciseDelta
+	}
+
+	// 16-bit inputs produce 16-bit output in high precision mode.
+	wide := opts.HighPrecision && (img.BPC == 8 || img2.BPC == 8)
+	var out draw.Image
+	switch {
+	case !render:
+	case wide:
+		out = image.NewRGBA64(img.Bounds())
+	default:
+		out = image.NewRGBA(img.Bounds())
+	}
+	output := &Image{Image: out, Format: img.Format}
+
+	// Map of different pixels to find clusters. Every row writes only its
+	// own part of the slice, so no locking is needed.
+	var diffs []bool
+	if opts.Connectivity > 0 {
+		diffs = make([]bool, img.Size())
+	}
+	markDiff := func(pt image.Point) {
+		if diffs != nil {
+			pt = pt.Sub(img.Bounds().Min)
+			diffs[pt.Y*img.Bounds().Dx()+pt.X] = true
+		}
+	}
+
+	// Rows are split into bands, processed by the pool of workers. Every
+	// worker collects its own partial result, which are merged once all
+	// bands are done. The mutex is taken only to report the progress.
+	compareRow := func(y int, row *Result) {
+		for x := img.Bounds().Min.X; x < img.Bounds().Max.X; x++ {
+			point := image.Pt(x, y)
+			if skipping && opts.Skipped(point) {
+				row.Ignored++
+				if render {
+					out.Set(x, y, opts.IgnoreColor)
+				}
+				continue
+			}
+			outside := extra && !point.In(overlap)
+			if outside && opts.SizePolicy == SizeOverlap {
+				row.addExtra(point)
+				markDiff(point)
+				if render {
+					out.Set(x, y, opts.ExtraColor)
+				}
+				continue
+			}
+			pos := img.Position(point)
+			delta := deltaOf(img2, pos, pos, metric)
+			row.add(delta)
+
+			shifted := math.Abs(delta) > maxDelta && opts.ShiftRadius > 0 &&
+				img.nearMatch(img2, point, opts.ShiftRadius, metric,
+					maxDelta, opts.HighPrecision) &&
+				img2.nearMatch(img, point, opts.ShiftRadius, metric,
+					maxDelta, opts.HighPrecision)
+			if shifted {
+				row.Shifted++
+			}
+
+			if math.Abs(delta) > maxDelta && !shifted {
+				if !opts.IncludeAA &&
+					(img.detectAA(img2, point, opts) ||
+						img2.detectAA(img, point, opts)) {
+					row.AA++
+					if render && !opts.DiffMask {
+						out.Set(x, y, opts.AAColor)
+					}
+				} else {
+					diffColor := getDiffColor(opts, magnitude(metric, delta))
+					if outside {
+						diffColor = opts.ExtraColor
+						row.Extra++
+					}
+					if render {
+						out.Set(x, y, diffColor)
+					}
+					row.addDiff(point, delta)
+					markDiff(point)
+				}
+			} else if render && !opts.DiffMask {
+				r, g, b, a := img.At(x, y).RGBA()
+				gray := NewColor(r, g, b, a).BlendToGray(opts.Alpha)
+				if wide {
+					gray = NewColor(r, g, b, a).blendToGray16(opts.Alpha)
+				}
+				if opts.Colormap != nil && opts.Faint && delta != 0 {
+					heat := opts.Colormap.At(math.Abs(magnitude(metric, delta)))
+					gray = mix(color.RGBAModel.Convert(gray).(color.RGBA),
+						heat, faintAlpha)
+				}
+				out.Set(x, y, gray)
+			}
+		}
+	}
+
+	height := img.Bounds().Dy()
+	bands := (height + bandHeight - 1) / bandHeight
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
 	}
+	workers = intMin(workers, bands)
 
-	maxDelta := YIQDeltaMax * opts.Threshold * opts.Threshold
-	diff := 0
-	output := NewImage(img.Bounds().Dx(), img.Bounds().Dy(), img.Format)
+	// The comparison stops once the budget of differences is exceeded,
+	// unless the complete output is required.
+	budget := -1
+	if opts.hasBudget() {
+		total := img.Size()
+		if skipping {
+			total -= img.countSkipped(opts)
+		}
+		budget = opts.budget(total)
+	}
+	stoppable := budget >= 0 && !(render && opts.RenderExceeded)
 
-	// Looks like the mutex + WaitGroup is the fastest found solution by me.
-	// sync/atomic also shows the same results.
 	var wg sync.WaitGroup
 	var mu sync.Mutex
-	wg.Add(img.Bounds().Max.Y)
-	for y := img.Bounds().Min.Y; y < img.Bounds().Max.Y; y++ {
-		go func(y int) {
+	var next, found int64
+	var stopped int32
+	rows := 0
+	parts := make([]*Result, workers)
+	wg.Add(workers)
+	for i := range parts {
+		parts[i] = &Result{}
+		go func(part *Result) {
 			defer wg.Done()
-			for x := img.Bounds().Min.X; x < img.Bounds().Max.X; x++ {
-				point := image.Pt(x, y)
-				pos := img.Position(point)
-				delta := img.ColorDelta(img2, pos, pos, false)
-
-				if math.Abs(delta) > maxDelta {
-					if !opts.IncludeAA &&
-						(img.Antialiased(img2, point) ||
-							img2.Antialiased(img, point)) {
-						if opts.Output != nil && !opts.DiffMask {
-							output.Image.(*image.RGBA).Set(x, y, opts.AAColor)
-						}
-					} else {
-						if opts.Output != nil {
-							diffColor := getDiffColor(opts, delta)
-							output.Image.(*image.RGBA).Set(x, y, diffColor)
-						}
-						mu.Lock()
-						diff++
-						mu.Unlock()
-					}
-				} else if opts.Output != nil && !opts.DiffMask {
-					r, g, b, a := img.At(x, y).RGBA()
-					gray := NewColor(r, g, b, a).BlendToGray(opts.Alpha)
-					output.Image.(*image.RGBA).Set(x, y, gray)
+			for ctx.Err() == nil && atomic.LoadInt32(&stopped) == 0 {
+				band := int(atomic.AddInt64(&next, 1) - 1)
+				if band >= bands {
+					return
+				}
+				y1 := img.Bounds().Min.Y + band*bandHeight
+				y2 := intMin(y1+bandHeight, img.Bounds().Max.Y)
+				n := part.Diff
+				for y := y1; y < y2; y++ {
+					compareRow(y, part)
+				}
+				part.Total += (y2 - y1) * img.Bounds().Dx()
+				if stoppable && atomic.AddInt64(&found,
+					int64(part.Diff-n)) > int64(budget) {
+					atomic.StoreInt32(&stopped, 1)
+				}
+				if opts.Progress != nil {
+					mu.Lock()
+					rows += y2 - y1
+					opts.Progress(rows, height)
+					mu.Unlock()
 				}
 			}
-		}(y)
+		}(parts[i])
 	}
 
 	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+	for _, part := range parts {
+		res.merge(part)
+	}
+	res.Exceeded = budget >= 0 && res.Diff > budget
+	res.Partial = stopped == 1
+	res.Total -= res.Ignored
+	if res.Total > 0 {
+		res.MeanDelta = res.sumDelta / float64(res.Total)
+	}
 
-	// If no output given or there is no difference do not create diff file.
-	if opts.Output != nil {
-		err := output.Save(opts.Output)
-		if err != nil {
-			return -1, err
+	// Partial result has neither clusters nor output.
+	if res.Partial {
+		return res, nil, nil
+	}
+
+	if diffs != nil {
+		res.Clusters = findClusters(diffs, img.Bounds(), opts.Connectivity)
+		if opts.ClusterDistance > 0 {
+			res.Clusters = mergeClusters(res.Clusters, opts.ClusterDistance)
+		}
+		sortClusters(res.Clusters)
+		if render && opts.ClusterColor != nil {
+			DrawClusters(out, res.Clusters,
+				opts.ClusterColor)
 		}
 	}
-	return diff, nil
+
+	if render && opts.Colormap != nil && opts.Legend {
+		output.Image = opts.Colormap.withLegend(output.Image)
+	}
+	if render && opts.Composite != LayoutNone {
+		output = expected.composite(actual, output, img.Image, img2.Image,
+			opts)
+	}
+
+	if !render {
+		return res, nil, nil
+	}
+	return res, output, nil
+}
+
+// place draws both images on the common canvas according to the size policy
+// and the anchor of the options. The canvas keeps coordinates of the img.
+// Returns new images and their overlapping area.
+func (img *Image) place(img2 *Image, opts *Options) (*Image, *Image, image.Rectangle) {
+	r1 := img.Bounds()
+	r2 := image.Rectangle{r1.Min, r1.Min.Add(img2.Bounds().Size())}
+	switch opts.Anchor {
+	case AnchorCenter:
+		r2 = r2.Add(r1.Size().Sub(r2.Size()).Div(2))
+	case AnchorOffset:
+		r2 = r2.Add(opts.Offset)
+	}
+
+	canvas := r1.Union(r2)
+	pad := &image.Uniform{opts.PadColor}
+	var m1, m2 draw.Image = image.NewNRGBA(canvas), image.NewNRGBA(canvas)
+	if img.BPC == 8 || img2.BPC == 8 {
+		m1, m2 = image.NewNRGBA64(canvas), image.NewNRGBA64(canvas)
+	}
+	draw.Draw(m1, canvas, pad, image.Point{}, draw.Src)
+	draw.Draw(m2, canvas, pad, image.Point{}, draw.Src)
+	draw.Draw(m1, r1, img.Image, r1.Min, draw.Src)
+	draw.Draw(m2, r2, img2.Image, img2.Bounds().Min, draw.Src)
+
+	return NewImageFromImage(m1, img.Format),
+		NewImageFromImage(m2, img.Format),
+		r1.Intersect(r2)
+}
+
+// countSkipped counts pixels of the image skipped by the options.
+func (img *Image) countSkipped(opts *Options) int {
+	n := 0
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if opts.Skipped(image.Pt(x, y)) {
+				n++
+			}
+		}
+	}
+	return n
 }
 
-// getDiffColor get diff color.
+// getDiffColor get diff color. The delta is normalized by the metric, its
+// magnitude is used with the colormap.
 func getDiffColor(opts *Options, delta float64) color.Color {
+	if opts.Colormap != nil {
+		return opts.Colormap.At(math.Abs(delta))
+	}
 	diffColor := opts.DiffColor
 	if delta < 0 && opts.DiffColorAlt != nil {
 		diffColor = opts.DiffColorAlt
@@ -199,8 +528,15 @@ func (img *Image) Save(wr io.Writer) (err error) {
 		err = gif.Encode(wr, img.Image, nil)
 	case FormatJPEG:
 		err = jpeg.Encode(wr, img.Image, nil)
-	case FormatPNG:
+	case FormatPNG, FormatWebP:
+		// There is no WebP encoder, WebP images are saved as PNG.
 		err = png.Encode(wr, img.Image)
+	case FormatBMP:
+		err = bmp.Encode(wr, img.Image)
+	case FormatTIFF:
+		err = tiff.Encode(wr, img.Image, nil)
+	case FormatPBM, FormatPGM, FormatPPM:
+		err = encodeNetpbm(wr, img.Image, img.Format)
 	default:
 		err = ErrUnknownFormat
 	}
@@ -235,50 +571,69 @@ func (img *Image) Identical(img2 *Image) bool {
 	return bytes.Equal(img.Bytes(), img2.Bytes())
 }
 
-// Bytes are the raw bytes of the pixel data.
-// Reflection is never clear (https://go-proverbs.github.io/)
+// Bytes are the raw bytes of the normalized pixel data, see [Normalize].
 func (img *Image) Bytes() []byte {
-	val := reflect.ValueOf(img.Image)
-	ptr := reflect.Indirect(val)
-	pixs := ptr.FieldByName("Pix")
-	if pixs.IsValid() {
-		return pixs.Bytes()
+	switch m := Normalize(img.Image).(type) {
+	case *image.NRGBA:
+		return rectBytes(m.Pix, m.Stride, m.PixOffset(m.Rect.Min.X,
+			m.Rect.Min.Y), m.Rect.Dx()*4, m.Rect.Dy())
+	case *image.NRGBA64:
+		return rectBytes(m.Pix, m.Stride, m.PixOffset(m.Rect.Min.X,
+			m.Rect.Min.Y), m.Rect.Dx()*8, m.Rect.Dy())
 	}
+	return []byte{}
+}
 
-	// For JPEG
-	y := ptr.FieldByName("Y")
-	if y.IsValid() {
-		return y.Bytes()
+// rectBytes returns the bytes of the rows of the buffer without gaps between
+// the rows. The buffer is returned as is if there are no gaps.
+func rectBytes(pix []byte, stride, offset, width, height int) []byte {
+	if stride == width {
+		return pix[offset : offset+width*height]
 	}
-	return []byte{}
+	bs := make([]byte, 0, width*height)
+	for y := 0; y < height; y++ {
+		bs = append(bs, pix[offset+y*stride:offset+y*stride+width]...)
+	}
+	return bs
 }
 
 // Stride gets the stride from the image. The default value is 1.
-// Reflection is never clear (https://go-proverbs.github.io/)
 func (img *Image) Stride() int {
-	val := reflect.ValueOf(img.Image)
-	ptr := reflect.Indirect(val)
-	stride := ptr.FieldByName("Stride")
-	if stride.IsValid() {
-		return int(stride.Int()) // int64
-	}
-
-	// for JPEG
-	strideY := ptr.FieldByName("YStride")
-	if strideY.IsValid() {
-		return int(strideY.Int())
+	switch m := img.Image.(type) {
+	case *image.NRGBA:
+		return m.Stride
+	case *image.NRGBA64:
+		return m.Stride
+	case *image.RGBA:
+		return m.Stride
+	case *image.RGBA64:
+		return m.Stride
+	case *image.Gray:
+		return m.Stride
+	case *image.Gray16:
+		return m.Stride
+	case *image.Alpha:
+		return m.Stride
+	case *image.Alpha16:
+		return m.Stride
+	case *image.CMYK:
+		return m.Stride
+	case *image.Paletted:
+		return m.Stride
+	case *image.YCbCr:
+		return m.YStride
 	}
 	return 1
 }
 
-// Position is the position of the pixel in the array of bytes.
+// Position is the position of the pixel in the [Image.PixData].
 //
 // Formula
 //
-//	(y2-y1)*Stride + (x2-x1)*BPC
+//	((y2-y1)*width + (x2-x1))*4
 func (img *Image) Position(p image.Point) int {
-	return (p.Y-img.Bounds().Min.Y)*img.Stride() +
-		(p.X-img.Bounds().Min.X)*img.BPC
+	b := img.Bounds()
+	return ((p.Y-b.Min.Y)*b.Dx() + p.X - b.Min.X) * channels
 }
 
 // BytesPerColor resolves the count of the bytes per color: 1, 2, 4, or 8.
@@ -307,86 +662,80 @@ func (img *Image) BytesPerColor() int {
 // versa. If the argument onlyY is true, the only brightness level will be
 // returned (Y component of the YIQ color space).
 func (img *Image) ColorDelta(img2 *Image, m, n int, onlyY bool) float64 {
-	px1 := img.PixData
-	px2 := img2.PixData
-	var r1, g1, b1, a1 uint32
-	var r2, g2, b2, a2 uint32
-
-	switch img.BPC {
-	case 1:
-		r1, g1, b1, a1 = px1[m], px1[m], px1[m], px1[m]
-		r2, g2, b2, a2 = px2[n], px2[n], px2[n], px2[n]
-	case 2:
-		r1, g1, b1, a1 = px1[m], px1[m], px1[m], px1[m+1]
-		r2, g2, b2, a2 = px2[n], px2[n], px2[n], px2[n+1]
-	case 4:
-		r1, g1, b1, a1 = px1[m], px1[m+1], px1[m+2], px1[m+3]
-		r2, g2, b2, a2 = px2[n], px2[n+1], px2[n+2], px2[n+3]
-	// NOTE not sure about this
-	case 8:
-		r1, r2 = px1[0]*px1[1], px2[0]*px2[1]
-		g1, g2 = px1[2]*px1[3], px2[2]*px2[3]
-		b1, b2 = px1[4]*px1[5], px2[4]*px2[5]
-		a1, a2 = px1[6]*px1[7], px2[6]*px2[7]
-	}
-
-	switch img.Image.(type) {
-	case *image.Paletted:
-		x := px1[m]
-		y := px2[n]
-		palette1 := img.Image.(*image.Paletted).Palette
-		palette2 := img2.Image.(*image.Paletted).Palette
-		r1, g1, b1, a1 = palette1[x].RGBA()
-		r2, g2, b2, a2 = palette2[y].RGBA()
-		r1 >>= 8
-		g1 >>= 8
-		b1 >>= 8
-		a1 >>= 8
-		r2 >>= 8
-		g2 >>= 8
-		b2 >>= 8
-		a2 >>= 8
-		// case *image.YCbCr, *image.NYCbCrA:
-		// Maybe do something here later...
-	}
-
-	color1 := NewColor(r1, g1, b1, a1)
-	color2 := NewColor(r2, g2, b2, a2)
+	color1, color2 := img.colors(img2, m, n)
 
 	// If all colors are the same then zero delta.
-	if color1.Equals(color2) {
+	if color1.Equals(&color2) {
 		return 0
 	}
 
-	if color1.A < 0xff {
-		color1 = color1.Blend(float64(color1.A) / 0xff)
+	c1, c2 := color1.rgb(), color2.rgb()
+	if onlyY {
+		return c1.y() - c2.y()
 	}
+	return YIQ{}.Delta(c1, c2)
+}
+
+// MetricDelta is the distance between colors at the pixel's position
+// measured with the metric. Returns a negative value if the img2 pixel is
+// darker, and vice versa.
+func (img *Image) MetricDelta(img2 *Image, m, n int, metric Metric) float64 {
+	color1, color2 := img.colors(img2, m, n)
 
-	if color2.A < 0xff {
-		color2 = color2.Blend(float64(color2.A) / 0xff)
+	// If all colors are the same then zero delta.
+	if color1.Equals(&color2) {
+		return 0
 	}
+	return metric.Delta(color1.rgb(), color2.rgb())
+}
 
-	y1, y2 := color1.Y(), color2.Y()
-	y := y1 - y2
-	if onlyY {
-		return y
+// colors returns colors of both images at the given positions.
+func (img *Image) colors(img2 *Image, m, n int) (Color, Color) {
+	return img.colorAt(m), img2.colorAt(n)
+}
+
+// colorAt returns 8-bit color at the position of the pixel data.
+func (img *Image) colorAt(i int) Color {
+	px := img.PixData[i : i+channels]
+	if img.BPC == 8 {
+		return Color{px[0] >> 8, px[1] >> 8, px[2] >> 8, px[3] >> 8}
 	}
+	return Color{px[0], px[1], px[2], px[3]}
+}
 
-	i := color1.I() - color2.I()
-	q := color1.Q() - color2.Q()
-	delta := 0.5053*y*y + 0.299*i*i + 0.1957*q*q
+// preciseDelta is like [Image.MetricDelta], but keeps 16 bits of the
+// channels. Colors are measured in the same 8-bit scale without rounding, so
+// the threshold has the same meaning.
+func (img *Image) preciseDelta(img2 *Image, m, n int, metric Metric) float64 {
+	color1, color2 := img.color16At(m), img2.color16At(n)
+	if color1.Equals(&color2) {
+		return 0
+	}
+	return metric.Delta(color1.rgb16(), color2.rgb16())
+}
 
-	if y1 > y2 {
-		return -delta
+// color16At returns 16-bit color at the position of the pixel data.
+func (img *Image) color16At(i int) Color {
+	px := img.PixData[i : i+channels]
+	if img.BPC == 8 {
+		return Color{px[0], px[1], px[2], px[3]}
 	}
-	return delta
+	return Color{px[0] * 0x101, px[1] * 0x101, px[2] * 0x101, px[3] * 0x101}
 }
 
-// Uint32 converts image.Bytes() into a []uint32 slice. Be careful; this might
-// be an expensive operation, used once and cached in image.PixData on image
-// loading
+// Uint32 converts the normalized pixel data into a []uint32 slice, one value
+// per channel. 16-bit images have 16-bit values. Be careful; this might be an
+// expensive operation, used once and cached in image.PixData on image
+// loading.
 func (img *Image) Uint32() []uint32 {
 	bs := img.Bytes()
+	if _, ok := Normalize(img.Image).(*image.NRGBA64); ok {
+		ui32 := make([]uint32, len(bs)/2)
+		for i := range ui32 {
+			ui32[i] = uint32(bs[i*2])<<8 | uint32(bs[i*2+1])
+		}
+		return ui32
+	}
 	ui32 := make([]uint32, len(bs))
 	for i, b := range bs {
 		ui32[i] = uint32(b)
@@ -396,14 +745,19 @@ func (img *Image) Uint32() []uint32 {
 
 // Antialiased checks that the point is anti-aliased.
 //
-// NOTE Probably, better algorithms are required here.
+// NOTE Probably, better algorithms are required here, see [AAGradient].
 func (img *Image) Antialiased(img2 *Image, pt image.Point) bool {
+	return img.antialiased(img2, pt, 1, 2)
+}
+
+// antialiased is like [Image.Antialiased], but the window has the given
+// radius and the pixel has at most n same neighbors.
+func (img *Image) antialiased(img2 *Image, pt image.Point, radius, n int) bool {
 	neibrs := 0
-	n := 2
-	x1 := intMax(pt.X-1, img.Bounds().Min.X)
-	y1 := intMax(pt.Y-1, img.Bounds().Min.Y)
-	x2 := intMin(pt.X+1, img.Bounds().Max.X-1)
-	y2 := intMin(pt.Y+1, img.Bounds().Max.Y-1)
+	x1 := intMax(pt.X-radius, img.Bounds().Min.X)
+	y1 := intMax(pt.Y-radius, img.Bounds().Min.Y)
+	x2 := intMin(pt.X+radius, img.Bounds().Max.X-1)
+	y2 := intMin(pt.Y+radius, img.Bounds().Max.Y-1)
 	pos := img.Position(pt)
 
 	if pt.X == x1 || pt.X == x2 || pt.Y == y1 || pt.Y == y2 {
@@ -442,20 +796,68 @@ func (img *Image) Antialiased(img2 *Image, pt image.Point) bool {
 		return false
 	}
 
-	return (img.SameNeighbors(image.Pt(minX, minY), n) &&
-		img2.SameNeighbors(image.Pt(minX, minY), n)) ||
-		(img.SameNeighbors(image.Pt(maxX, maxY), n) &&
-			img2.SameNeighbors(image.Pt(maxX, maxY), n))
+	return (img.sameNeighbors(image.Pt(minX, minY), radius, n) &&
+		img2.sameNeighbors(image.Pt(minX, minY), radius, n)) ||
+		(img.sameNeighbors(image.Pt(maxX, maxY), radius, n) &&
+			img2.sameNeighbors(image.Pt(maxX, maxY), radius, n))
+}
+
+// detectAA checks that the point is anti-aliased with the detector and the
+// parameters of the options.
+func (img *Image) detectAA(img2 *Image, pt image.Point, opts *Options) bool {
+	radius := opts.AARadius
+	if radius <= 0 {
+		radius = defaultOptions.AARadius
+	}
+	if opts.AADetector == AAGradient {
+		return img.gradientAA(img2, pt, radius)
+	}
+	neighbors := opts.AANeighbors
+	if neighbors <= 0 {
+		neighbors = defaultOptions.AANeighbors
+	}
+	return img.antialiased(img2, pt, radius, neighbors)
+}
+
+// nearMatch reports whether any pixel of the second image within the radius
+// around the point is within the threshold of the pixel of the image.
+func (img *Image) nearMatch(img2 *Image, pt image.Point, radius int,
+	metric Metric, maxDelta float64, precise bool) bool {
+	deltaOf := img.MetricDelta
+	if precise {
+		deltaOf = img.preciseDelta
+	}
+	b := img.Bounds().Intersect(image.Rect(pt.X-radius, pt.Y-radius,
+		pt.X+radius+1, pt.Y+radius+1))
+	pos := img.Position(pt)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if x == pt.X && y == pt.Y {
+				continue
+			}
+			pos2 := img2.Position(image.Pt(x, y))
+			if math.Abs(deltaOf(img2, pos, pos2, metric)) <= maxDelta {
+				return true
+			}
+		}
+	}
+	return false
 }
 
 // SameNeighbors determines whether a pixel has n+ adjacent pixels that are
 // the same color.
 func (img *Image) SameNeighbors(pt image.Point, n int) bool {
+	return img.sameNeighbors(pt, 1, n)
+}
+
+// sameNeighbors is like [Image.SameNeighbors] within the window of the given
+// radius.
+func (img *Image) sameNeighbors(pt image.Point, radius, n int) bool {
 	neibrs := 0
-	x1 := intMax(pt.X-1, img.Bounds().Min.X)
-	y1 := intMax(pt.Y-1, img.Bounds().Min.Y)
-	x2 := intMin(pt.X+1, img.Bounds().Max.X-1)
-	y2 := intMin(pt.Y+1, img.Bounds().Max.Y-1)
+	x1 := intMax(pt.X-radius, img.Bounds().Min.X)
+	y1 := intMax(pt.Y-radius, img.Bounds().Min.Y)
+	x2 := intMin(pt.X+radius, img.Bounds().Max.X-1)
+	y2 := intMin(pt.Y+radius, img.Bounds().Max.Y-1)
 	pos1 := img.Position(pt)
 
 	if pt.X == x1 || pt.X == x2 || pt.Y == y1 || pt.Y == y2 {
@@ -470,7 +872,7 @@ func (img *Image) SameNeighbors(pt image.Point, n int) bool {
 
 			pos2 := img.Position(image.Pt(x, y))
 			ok := true
-			for i := 0; i < img.BPC; i++ {
+			for i := 0; i < channels; i++ {
 				if img.PixData[pos1+i] != img.PixData[pos2+i] {
 					ok = false
 					break
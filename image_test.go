@@ -1,11 +1,14 @@
 package pixmatch
 
 import (
+	"bytes"
+	"context"
 	"image"
 	"image/color"
+	"image/draw"
 	_ "image/gif"
 	_ "image/jpeg"
-	_ "image/png"
+	"image/png"
 	"os"
 	"reflect"
 	"testing"
@@ -171,14 +174,14 @@ func TestBytes(t *testing.T) {
 		"./samples/models/nrgba32.png": 32,
 		"./samples/models/rgb.png":     16,
 		"./samples/models/rgb32.png":   32,
-		"./samples/models/gray.png":    4,
-		"./samples/models/gray32.png":  8,
+		"./samples/models/gray.png":    16,
+		"./samples/models/gray32.png":  32,
 		"./samples/models/graya.png":   16,
 		"./samples/models/graya32.png": 32,
-		"./samples/models/palette.png": 4,
+		"./samples/models/palette.png": 16,
 		"./samples/models/alpha.png":   16,
 		"./samples/models/alpha32.png": 16,
-		"./samples/models/tt.jpg":      64,
+		"./samples/models/tt.jpg":      16,
 	}
 	for path, bits := range pairs {
 		img, _ := NewImageFromPath(path)
@@ -327,7 +330,7 @@ var testPairs = []testPair{
 		pathA:        "./samples/gray8-a.png",
 		pathB:        "./samples/gray8-b.png",
 		pathDiff:     "./samples/gray8-diff.png",
-		expectedDiff: 4,
+		expectedDiff: 6,
 		skip:         false,
 		options:      NewOptions().SetIncludeAA(true),
 	},
@@ -336,7 +339,7 @@ var testPairs = []testPair{
 		pathA:        "./samples/gray16-a.png",
 		pathB:        "./samples/gray16-b.png",
 		pathDiff:     "./samples/gray16-diff.png",
-		expectedDiff: 15,
+		expectedDiff: 5,
 		skip:         false,
 		options:      NewOptions().SetIncludeAA(true),
 	},
@@ -372,7 +375,7 @@ var testPairs = []testPair{
 		pathA:        "./samples/bird-a.jpg",
 		pathB:        "./samples/bird-b.jpg",
 		pathDiff:     "./samples/bird-diff.jpg",
-		expectedDiff: 1102,
+		expectedDiff: 2223,
 		skip:         false,
 		options:      NewOptions().SetAlpha(.5),
 	},
@@ -499,3 +502,357 @@ func TestImageCompare(t *testing.T) {
 		})
 	}
 }
+
+func TestCompare_Models(t *testing.T) {
+	pairs := []struct {
+		a, b string
+		want int
+	}{
+		{"alpha.png", "alpha32.png", 0},
+		{"gray.png", "gray32.png", 0},
+		{"graya.png", "graya32.png", 0},
+		{"nrgba.png", "nrgba32.png", 0},
+		{"nrgba.png", "palette.png", 0},
+		{"rgb.png", "rgb32.png", 0},
+		{"rgb.png", "tt.jpg", 0},
+		{"rgb.png", "nrgba.png", 1},
+		{"gray.png", "graya.png", 1},
+		{"rgb.png", "gray.png", 3},
+	}
+	for _, p := range pairs {
+		imageA, err := NewImageFromPath("./samples/models/" + p.a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		imageB, err := NewImageFromPath("./samples/models/" + p.b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		diff, err := imageA.Compare(imageB, NewOptions())
+		if err != nil {
+			t.Error(err)
+		}
+		if diff != p.want {
+			t.Errorf("%v and %v: expected %v got %v", p.a, p.b, p.want, diff)
+		}
+	}
+}
+
+// uniform creates the image of the model filled with the single color.
+func uniform(m draw.Image, c color.Color) *Image {
+	draw.Draw(m, m.Bounds(), &image.Uniform{c}, image.Point{}, draw.Src)
+	return NewImageFromImage(m, DefaultFormat)
+}
+
+// Expectations of gray8, gray16 and bird samples changed, when pixels of all
+// models were normalized, because of the following cases.
+func TestCompare_NormalizedModels(t *testing.T) {
+	r := image.Rect(0, 0, 4, 4)
+	ycbcr := func(cr uint8) *Image {
+		m := image.NewYCbCr(r, image.YCbCrSubsampleRatio444)
+		for i := range m.Y {
+			m.Y[i], m.Cb[i], m.Cr[i] = 0x80, 0x80, cr
+		}
+		return NewImageFromImage(m, FormatJPEG)
+	}
+	tests := []struct {
+		name       string
+		img1, img2 *Image
+		want       int
+	}{
+		// Gray level was read as alpha too, so dark pixels were almost
+		// transparent and blended with white.
+		{"Gray", uniform(image.NewGray(r), color.Gray{0}),
+			uniform(image.NewGray(r), color.Gray{0x30}), 16},
+		// The low byte of 16-bit gray was read as alpha.
+		{"Gray16", uniform(image.NewGray16(r), color.Gray16{0x8000}),
+			uniform(image.NewGray16(r), color.Gray16{0x80ff}), 0},
+		// Only the luma plane of JPEG images was compared.
+		{"YCbCr", ycbcr(0x80), ycbcr(0xf0), 16},
+	}
+	for _, tt := range tests {
+		for _, hp := range []bool{false, true} {
+			diff, err := tt.img1.Compare(tt.img2,
+				NewOptions().SetHighPrecision(hp))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff != tt.want {
+				t.Errorf("%v (%v): expected %v got %v", tt.name, hp, tt.want,
+					diff)
+			}
+		}
+	}
+}
+
+// newUniformImage creates an image filled with the single color.
+func newUniformImage(w, h int, c color.Color) *Image {
+	m := image.NewNRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(m, m.Bounds(), &image.Uniform{c}, image.Point{}, draw.Src)
+	return NewImageFromImage(m, DefaultFormat)
+}
+
+func TestCompare_SizePolicy(t *testing.T) {
+	white := color.NRGBA{0xff, 0xff, 0xff, 0xff}
+	black := color.NRGBA{0, 0, 0, 0xff}
+	img1 := newUniformImage(4, 4, white)
+	img2 := newUniformImage(2, 2, white)
+
+	tests := []struct {
+		name  string
+		opts  *Options
+		diff  int
+		extra int
+	}{
+		{"Overlap", NewOptions().SetSizePolicy(SizeOverlap), 12, 12},
+		{"OverlapCenter", NewOptions().SetSizePolicy(SizeOverlap).
+			SetAnchor(AnchorCenter), 12, 12},
+		{"OverlapOffset", NewOptions().SetSizePolicy(SizeOverlap).
+			SetOffset(image.Pt(3, 3)), 24, 24},
+		{"PadSame", NewOptions().SetSizePolicy(SizePad).
+			SetPadColor(white), 0, 0},
+		{"PadOther", NewOptions().SetSizePolicy(SizePad).
+			SetPadColor(black).SetIncludeAA(true), 12, 12},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			res, err := img1.CompareResult(img2, tt.opts.SetOutput(&buf))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Diff != tt.diff || res.Extra != tt.extra {
+				t.Errorf("Expected %v (%v extra) got %v (%v extra)",
+					tt.diff, tt.extra, res.Diff, res.Extra)
+			}
+			classified := res.Darker + res.Lighter
+			if tt.opts.SizePolicy == SizeOverlap {
+				classified += res.Extra
+			}
+			if classified != res.Diff {
+				t.Errorf("Expected %v got %v", res.Diff, classified)
+			}
+			if tt.extra > 0 {
+				out, err := png.Decode(&buf)
+				if err != nil {
+					t.Fatal(err)
+				}
+				want := color.RGBAModel.Convert(tt.opts.ExtraColor)
+				got := color.RGBAModel.Convert(out.At(3, 3))
+				if tt.name == "OverlapOffset" {
+					got = color.RGBAModel.Convert(out.At(0, 0))
+				}
+				if got != want {
+					t.Errorf("Expected %v got %v", want, got)
+				}
+			}
+		})
+	}
+
+	_, err := img1.Compare(img2, nil)
+	if err != ErrDimensionsDoNotMatch {
+		t.Errorf("Expected %v got %v", ErrDimensionsDoNotMatch, err)
+	}
+}
+
+func TestCompare_HighPrecision(t *testing.T) {
+	m1 := image.NewNRGBA64(image.Rect(0, 0, 4, 4))
+	m2 := image.NewNRGBA64(image.Rect(0, 0, 4, 4))
+	draw.Draw(m1, m1.Bounds(), &image.Uniform{color.NRGBA64{0x8000,
+		0x8000, 0x8000, 0xffff}}, image.Point{}, draw.Src)
+	// Differs only in the low byte of the red channel.
+	draw.Draw(m2, m2.Bounds(), &image.Uniform{color.NRGBA64{0x80ff,
+		0x8000, 0x8000, 0xffff}}, image.Point{}, draw.Src)
+	img1 := NewImageFromImage(m1, DefaultFormat)
+	img2 := NewImageFromImage(m2, DefaultFormat)
+
+	opts := NewOptions().SetThreshold(0).SetIncludeAA(true)
+	diff, err := img1.Compare(img2, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff != 0 {
+		t.Errorf("Expected %v got %v", 0, diff)
+	}
+
+	var buf bytes.Buffer
+	diff, err = img1.Compare(img2, opts.SetHighPrecision(true).SetOutput(&buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff != 16 {
+		t.Errorf("Expected %v got %v", 16, diff)
+	}
+	out, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := out.(*image.RGBA64); !ok {
+		t.Errorf("Expected %T got %T", &image.RGBA64{}, out)
+	}
+
+	// The threshold has the same meaning for 8-bit and 16-bit images.
+	imageA, _ := NewImageFromPath("./samples/gray16-a.png")
+	imageB, _ := NewImageFromPath("./samples/gray16-b.png")
+	for _, threshold := range []float64{0.05, 0.1, 0.5} {
+		opts := NewOptions().SetThreshold(threshold)
+		want, _ := imageA.Compare(imageB, opts)
+		got, _ := imageA.Compare(imageB, opts.SetHighPrecision(true))
+		if intAbs(got-want) > 1 {
+			t.Errorf("Threshold %v: expected %v got %v", threshold, want, got)
+		}
+	}
+}
+
+func TestCompareContext(t *testing.T) {
+	imageA, _ := NewImageFromPath("./samples/form-a.png")
+	imageB, _ := NewImageFromPath("./samples/form-b.png")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	diff, err := imageA.CompareContext(ctx, imageB, nil)
+	if err != context.Canceled || diff != -1 {
+		t.Errorf("Expected %v got %v (%v)", context.Canceled, err, diff)
+	}
+
+	// Cancel in the middle of the comparison.
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	opts := NewOptions().SetProgress(func(done, total int) {
+		if done >= total/2 {
+			cancel()
+		}
+	})
+	_, err = imageA.CompareResultContext(ctx, imageB, opts)
+	if err != context.Canceled {
+		t.Errorf("Expected %v got %v", context.Canceled, err)
+	}
+
+	diff, err = imageA.CompareContext(context.Background(), imageB, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff != 2909 {
+		t.Errorf("Expected %v got %v", 2909, diff)
+	}
+}
+
+func TestCompare_Progress(t *testing.T) {
+	imageA, _ := NewImageFromPath("./samples/form-a.png")
+	imageB, _ := NewImageFromPath("./samples/form-b.png")
+	last := 0
+	opts := NewOptions().SetProgress(func(done, total int) {
+		if done <= last || done > total || total != imageA.Bounds().Dy() {
+			t.Errorf("Unexpected progress %v/%v", done, total)
+		}
+		last = done
+	})
+	if _, err := imageA.Compare(imageB, opts); err != nil {
+		t.Fatal(err)
+	}
+	if last != imageA.Bounds().Dy() {
+		t.Errorf("Expected %v got %v", imageA.Bounds().Dy(), last)
+	}
+}
+
+func TestCompare_MaxDiff(t *testing.T) {
+	imageA, _ := NewImageFromPath("./samples/form-a.png")
+	imageB, _ := NewImageFromPath("./samples/form-b.png")
+
+	tests := []struct {
+		name     string
+		opts     *Options
+		exceeded bool
+		partial  bool
+		output   bool
+	}{
+		{"Disabled", NewOptions(), false, false, true},
+		{"Negative", NewOptions().SetMaxDiff(10).SetMaxDiff(-1), false, false,
+			true},
+		{"Within", NewOptions().SetMaxDiff(2909), false, false, true},
+		{"Exceeded", NewOptions().SetMaxDiff(10), true, true, false},
+		{"Zero", NewOptions().SetMaxDiff(0), true, true, false},
+		{"PercentWithin", NewOptions().SetMaxDiffPercent(6), false, false, true},
+		{"PercentExceeded", NewOptions().SetMaxDiffPercent(5), true, true,
+			false},
+		{"RenderExceeded", NewOptions().SetMaxDiff(10).
+			SetRenderExceeded(true), true, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			res, err := imageA.CompareResult(imageB, tt.opts.SetOutput(&buf))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Exceeded != tt.exceeded || res.Partial != tt.partial {
+				t.Errorf("Expected %v (%v partial) got %v (%v partial)",
+					tt.exceeded, tt.partial, res.Exceeded, res.Partial)
+			}
+			if !res.Partial && res.Diff != 2909 {
+				t.Errorf("Expected %v got %v", 2909, res.Diff)
+			}
+			if (buf.Len() > 0) != tt.output {
+				t.Errorf("Expected output %v got %v", tt.output, buf.Len() > 0)
+			}
+		})
+	}
+
+	// Zero value of options has no budget.
+	res, err := imageA.CompareResult(imageB, &Options{Threshold: 0.1,
+		Alpha: 0.1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Exceeded || res.Partial || res.Total != imageA.Size() {
+		t.Errorf("Expected complete result got %v of %v pixels (%v partial)",
+			res.Total, imageA.Size(), res.Partial)
+	}
+
+	// Without the output the comparison stops too.
+	opts := NewOptions().SetMaxDiff(10).SetRenderExceeded(true)
+	res, err = imageA.CompareResult(imageB, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Partial || res.Total >= imageA.Size() {
+		t.Errorf("Expected partial result got %v of %v pixels", res.Total,
+			imageA.Size())
+	}
+}
+
+func TestCompare_ShiftRadius(t *testing.T) {
+	white := color.NRGBA{0xff, 0xff, 0xff, 0xff}
+	black := color.NRGBA{0, 0, 0, 0xff}
+	img1 := newUniformImage(20, 20, white)
+	img2 := newUniformImage(20, 20, white)
+	for y := 0; y < 20; y++ {
+		img1.Image.(*image.NRGBA).Set(10, y, black)
+		img2.Image.(*image.NRGBA).Set(11, y, black)
+	}
+	// The dot exists only in the second image.
+	img2.Image.(*image.NRGBA).Set(3, 3, black)
+	img1.cache()
+	img2.cache()
+
+	tests := []struct {
+		radius  int
+		diff    int
+		shifted int
+	}{
+		{0, 41, 0},
+		{1, 1, 40},
+		{3, 1, 40},
+	}
+	for _, tt := range tests {
+		opts := NewOptions().SetIncludeAA(true).SetShiftRadius(tt.radius)
+		res, err := img1.CompareResult(img2, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Diff != tt.diff || res.Shifted != tt.shifted {
+			t.Errorf("Radius %v: expected %v (%v shifted) got %v (%v shifted)",
+				tt.radius, tt.diff, tt.shifted, res.Diff, res.Shifted)
+		}
+	}
+}
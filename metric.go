@@ -0,0 +1,261 @@
+package pixmatch
+
+import "math"
+
+// RGB is the opaque color with float components in range [0, 0xff]. It is
+// used to measure the difference between colors with the [Metric].
+type RGB struct {
+	R, G, B float64
+}
+
+// Metric is the measure of the difference between two colors.
+type Metric interface {
+	// Delta returns the difference between two colors. Returns a negative
+	// value if the second color is darker, and vice versa.
+	Delta(c1, c2 RGB) float64
+
+	// Limit converts the threshold in range [0, 1] to the maximum absolute
+	// delta, which is not considered as the difference.
+	Limit(threshold float64) float64
+}
+
+// magnitude normalizes the delta of the metric into range [-1, 1] on the
+// same scale as the threshold, so the delta at the threshold t has the
+// magnitude t. Custom metrics are expected to have linear limits.
+func magnitude(m Metric, delta float64) float64 {
+	v := math.Min(math.Abs(delta)/m.Limit(1), 1)
+	if _, ok := m.(YIQ); ok {
+		v = math.Sqrt(v)
+	}
+	return math.Copysign(v, delta)
+}
+
+// YIQ is the squared YIQ distance between colors. This is the default
+// metric, the same as used in [Pixelmatch.js]. Limit is normalized by
+// YIQDeltaMax and squared threshold.
+//
+// [Pixelmatch.js]: https://github.com/mapbox/pixelmatch
+type YIQ struct{}
+
+// Delta returns the squared YIQ distance between colors.
+func (YIQ) Delta(c1, c2 RGB) float64 {
+	y1, y2 := c1.y(), c2.y()
+	y := y1 - y2
+	i := c1.i() - c2.i()
+	q := c1.q() - c2.q()
+	delta := 0.5053*y*y + 0.299*i*i + 0.1957*q*q
+
+	if y1 > y2 {
+		return -delta
+	}
+	return delta
+}
+
+// Limit returns YIQDeltaMax multiplied by squared threshold.
+func (YIQ) Limit(threshold float64) float64 {
+	return YIQDeltaMax * threshold * threshold
+}
+
+// EuclideanRGB is the Euclidean distance between colors in RGB color space.
+// Limit is normalized by the maximum possible distance 255√3.
+type EuclideanRGB struct{}
+
+// Delta returns the Euclidean distance between colors.
+func (EuclideanRGB) Delta(c1, c2 RGB) float64 {
+	r, g, b := c1.R-c2.R, c1.G-c2.G, c1.B-c2.B
+	return signed(math.Sqrt(r*r+g*g+b*b), c1.y() > c2.y())
+}
+
+// Limit returns the threshold multiplied by the maximum possible distance.
+func (EuclideanRGB) Limit(threshold float64) float64 {
+	return threshold * 0xff * math.Sqrt(3)
+}
+
+// CIE76 is the ΔE*ab color difference, the Euclidean distance in CIELAB
+// color space. Read more https://en.wikipedia.org/wiki/Color_difference
+//
+// Limit is normalized by ΔE of 100, so the threshold 0.023 is ΔE of 2.3,
+// the just noticeable difference.
+type CIE76 struct{}
+
+// Delta returns ΔE*ab between colors.
+func (CIE76) Delta(c1, c2 RGB) float64 {
+	l1, a1, b1 := c1.Lab()
+	l2, a2, b2 := c2.Lab()
+	dl, da, db := l1-l2, a1-a2, b1-b2
+	return signed(math.Sqrt(dl*dl+da*da+db*db), l1 > l2)
+}
+
+// Limit returns the threshold multiplied by 100.
+func (CIE76) Limit(threshold float64) float64 {
+	return threshold * 100
+}
+
+// CIE94 is the ΔE*94 color difference with graphic arts weighting factors.
+// Read more https://en.wikipedia.org/wiki/Color_difference
+//
+// Limit is normalized by ΔE of 100, the same way as [CIE76].
+type CIE94 struct{}
+
+// Delta returns ΔE*94 between colors.
+func (CIE94) Delta(c1, c2 RGB) float64 {
+	const kL, k1, k2 = 1.0, 0.045, 0.015
+	l1, a1, b1 := c1.Lab()
+	l2, a2, b2 := c2.Lab()
+	dl := l1 - l2
+	ch1 := math.Hypot(a1, b1)
+	ch2 := math.Hypot(a2, b2)
+	dc := ch1 - ch2
+	da, db := a1-a2, b1-b2
+	dh2 := math.Max(da*da+db*db-dc*dc, 0)
+	sc := 1 + k1*ch1
+	sh := 1 + k2*ch1
+
+	dl /= kL
+	dc /= sc
+	return signed(math.Sqrt(dl*dl+dc*dc+dh2/(sh*sh)), l1 > l2)
+}
+
+// Limit returns the threshold multiplied by 100.
+func (CIE94) Limit(threshold float64) float64 {
+	return threshold * 100
+}
+
+// CIEDE2000 is the ΔE00 color difference, the most accurate perceptual
+// metric. Read more https://en.wikipedia.org/wiki/Color_difference
+//
+// Limit is normalized by ΔE of 100, the same way as [CIE76].
+type CIEDE2000 struct{}
+
+// Delta returns ΔE00 between colors.
+func (CIEDE2000) Delta(c1, c2 RGB) float64 {
+	l1, a1, b1 := c1.Lab()
+	l2, a2, b2 := c2.Lab()
+	return signed(deltaE2000(l1, a1, b1, l2, a2, b2), l1 > l2)
+}
+
+// Limit returns the threshold multiplied by 100.
+func (CIEDE2000) Limit(threshold float64) float64 {
+	return threshold * 100
+}
+
+// deltaE2000 calculates ΔE00 between two colors in CIELAB color space.
+func deltaE2000(l1, a1, b1, l2, a2, b2 float64) float64 {
+	cAvg := (math.Hypot(a1, b1) + math.Hypot(a2, b2)) / 2
+	c7 := math.Pow(cAvg, 7)
+	g := 0.5 * (1 - math.Sqrt(c7/(c7+math.Pow(25, 7))))
+	a1p, a2p := (1+g)*a1, (1+g)*a2
+	c1p, c2p := math.Hypot(a1p, b1), math.Hypot(a2p, b2)
+	h1p, h2p := hueAngle(b1, a1p), hueAngle(b2, a2p)
+
+	dlp := l2 - l1
+	dcp := c2p - c1p
+	dhp := 0.0
+	if c1p*c2p != 0 {
+		dhp = h2p - h1p
+		if dhp > 180 {
+			dhp -= 360
+		} else if dhp < -180 {
+			dhp += 360
+		}
+	}
+	dHp := 2 * math.Sqrt(c1p*c2p) * math.Sin(rad(dhp/2))
+
+	lpAvg := (l1 + l2) / 2
+	cpAvg := (c1p + c2p) / 2
+	hpAvg := h1p + h2p
+	if c1p*c2p != 0 {
+		if math.Abs(h1p-h2p) <= 180 {
+			hpAvg /= 2
+		} else if h1p+h2p < 360 {
+			hpAvg = (hpAvg + 360) / 2
+		} else {
+			hpAvg = (hpAvg - 360) / 2
+		}
+	}
+
+	t := 1 - 0.17*math.Cos(rad(hpAvg-30)) +
+		0.24*math.Cos(rad(2*hpAvg)) +
+		0.32*math.Cos(rad(3*hpAvg+6)) -
+		0.20*math.Cos(rad(4*hpAvg-63))
+	dTheta := 30 * math.Exp(-((hpAvg-275)/25)*((hpAvg-275)/25))
+	cp7 := math.Pow(cpAvg, 7)
+	rc := 2 * math.Sqrt(cp7/(cp7+math.Pow(25, 7)))
+	l50 := (lpAvg - 50) * (lpAvg - 50)
+	sl := 1 + 0.015*l50/math.Sqrt(20+l50)
+	sc := 1 + 0.045*cpAvg
+	sh := 1 + 0.015*cpAvg*t
+	rt := -math.Sin(rad(2*dTheta)) * rc
+
+	dl, dc, dh := dlp/sl, dcp/sc, dHp/sh
+	return math.Sqrt(dl*dl + dc*dc + dh*dh + rt*dc*dh)
+}
+
+// Lab converts sRGB color into CIELAB color space with D65 white point.
+// Read more https://en.wikipedia.org/wiki/CIELAB_color_space
+func (c RGB) Lab() (float64, float64, float64) {
+	r, g, b := linearize(c.R), linearize(c.G), linearize(c.B)
+	x := (0.4124564*r + 0.3575761*g + 0.1804375*b) / 0.95047
+	y := 0.2126729*r + 0.7151522*g + 0.0721750*b
+	z := (0.0193339*r + 0.1191920*g + 0.9503041*b) / 1.08883
+	fx, fy, fz := labF(x), labF(y), labF(z)
+	return 116*fy - 16, 500 * (fx - fy), 200 * (fy - fz)
+}
+
+// y is the RBG to Y (brightness) conversion, see [Color.Y].
+func (c RGB) y() float64 {
+	return c.R*0.29889531 + c.G*0.58662247 + c.B*0.11448223
+}
+
+// i is the RBG to I (chrominance) conversion, see [Color.I].
+func (c RGB) i() float64 {
+	return c.R*0.59597799 - c.G*0.27417610 - c.B*0.32180189
+}
+
+// q is the RBG to Q (chrominance) conversion, see [Color.Q].
+func (c RGB) q() float64 {
+	return c.R*0.21147017 - c.G*0.52261711 + c.B*0.31114694
+}
+
+// linearize converts sRGB component into linear RGB in range [0, 1].
+func linearize(v float64) float64 {
+	v /= 0xff
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+// labF is the nonlinear function of the CIELAB conversion.
+func labF(t float64) float64 {
+	const delta = 6.0 / 29
+	if t > delta*delta*delta {
+		return math.Cbrt(t)
+	}
+	return t/(3*delta*delta) + 4.0/29
+}
+
+// hueAngle returns the hue angle in degrees in range [0, 360).
+func hueAngle(b, a float64) float64 {
+	if a == 0 && b == 0 {
+		return 0
+	}
+	h := math.Atan2(b, a) * 180 / math.Pi
+	if h < 0 {
+		h += 360
+	}
+	return h
+}
+
+// rad converts degrees into radians.
+func rad(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+// signed returns the negative delta if the second color is darker.
+func signed(delta float64, darker bool) float64 {
+	if darker {
+		return -delta
+	}
+	return delta
+}
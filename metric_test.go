@@ -0,0 +1,90 @@
+package pixmatch
+
+import (
+	"math"
+	"testing"
+)
+
+var metrics = map[string]Metric{
+	"YIQ":          YIQ{},
+	"EuclideanRGB": EuclideanRGB{},
+	"CIE76":        CIE76{},
+	"CIE94":        CIE94{},
+	"CIEDE2000":    CIEDE2000{},
+}
+
+func TestLab(t *testing.T) {
+	pairs := map[RGB][3]float64{
+		{0xff, 0xff, 0xff}: {100, 0, 0},
+		{0, 0, 0}:          {0, 0, 0},
+		{0xff, 0, 0}:       {53.2408, 80.0925, 67.2032},
+	}
+	for c, want := range pairs {
+		l, a, b := c.Lab()
+		if math.Abs(l-want[0]) > 1e-3 || math.Abs(a-want[1]) > 1e-3 ||
+			math.Abs(b-want[2]) > 1e-3 {
+			t.Errorf("Expected %v got %v,%v,%v", want, l, a, b)
+		}
+	}
+}
+
+// Test data by Sharma, Wu, Dalal: "The CIEDE2000 Color-Difference Formula:
+// Implementation Notes, Supplementary Test Data, and Mathematical
+// Observations".
+func TestDeltaE2000(t *testing.T) {
+	pairs := [][7]float64{
+		{50, 2.6772, -79.7751, 50, 0, -82.7485, 2.0425},
+		{50, 0, 0, 50, -1, 2, 2.3669},
+		{50, 2.5, 0, 73, 25, -18, 27.1492},
+		{60.2574, -34.0099, 36.2677, 60.4626, -34.1751, 39.4387, 1.2644},
+		{22.7233, 20.0904, -46.694, 23.0331, 14.973, -42.5619, 2.0373},
+	}
+	for _, p := range pairs {
+		res := deltaE2000(p[0], p[1], p[2], p[3], p[4], p[5])
+		if math.Abs(res-p[6]) > 1e-4 {
+			t.Errorf("Expected %v got %v", p[6], res)
+		}
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	white := RGB{0xff, 0xff, 0xff}
+	gray := RGB{0x80, 0x80, 0x80}
+	for name, m := range metrics {
+		if d := m.Delta(white, white); d != 0 {
+			t.Errorf("%v: expected %v got %v", name, 0, d)
+		}
+		darker := m.Delta(white, gray)
+		lighter := m.Delta(gray, white)
+		if darker >= 0 || lighter <= 0 {
+			t.Errorf("%v: invalid signs %v, %v", name, darker, lighter)
+		}
+		if math.Abs(darker+lighter) > 1e-9 && name != "CIE94" {
+			t.Errorf("%v: expected symmetric values %v, %v", name, darker,
+				lighter)
+		}
+		if m.Limit(0) != 0 || m.Limit(1) <= m.Limit(0.5) {
+			t.Errorf("%v: invalid limits", name)
+		}
+	}
+}
+
+func TestCompare_Metrics(t *testing.T) {
+	img1, _ := NewImageFromPath("./samples/form-a.png")
+	img2, _ := NewImageFromPath("./samples/form-b.png")
+	for name, m := range metrics {
+		res, err := img1.CompareResult(img2, NewOptions().SetMetric(m))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Diff == 0 || res.Diff > img1.Size() {
+			t.Errorf("%v: invalid diff %v", name, res.Diff)
+		}
+	}
+
+	yiq, _ := img1.Compare(img2, NewOptions().SetMetric(YIQ{}))
+	def, _ := img1.Compare(img2, nil)
+	if yiq != def {
+		t.Errorf("Expected %v got %v", def, yiq)
+	}
+}
@@ -0,0 +1,109 @@
+package pixmatch
+
+import (
+	"image"
+	"image/color"
+)
+
+// channels is the number of values per pixel in [Image.PixData].
+const channels = 4
+
+// Normalize converts any image into the canonical [image.NRGBA] image, or
+// [image.NRGBA64] if the color model of the image has 16 bits per channel.
+// NRGBA and NRGBA64 images are returned as is.
+func Normalize(m image.Image) image.Image {
+	switch m.(type) {
+	case *image.NRGBA, *image.NRGBA64:
+		return m
+	}
+	switch m.ColorModel() {
+	case color.Gray16Model, color.Alpha16Model, color.RGBA64Model,
+		color.NRGBA64Model:
+		return toNRGBA64(m)
+	}
+	return toNRGBA(m)
+}
+
+// toNRGBA converts the image into NRGBA image. Common models are converted
+// without color.Color allocations.
+func toNRGBA(m image.Image) *image.NRGBA {
+	b := m.Bounds()
+	dst := image.NewNRGBA(b)
+	i := 0
+	switch src := m.(type) {
+	case *image.RGBA:
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				p := src.Pix[src.PixOffset(x, y):]
+				r, g, bl, a := p[0], p[1], p[2], p[3]
+				// Same rounding as color.NRGBAModel has.
+				if a != 0 && a != 0xff {
+					r = uint8(uint32(r) * 0xffff / uint32(a) >> 8)
+					g = uint8(uint32(g) * 0xffff / uint32(a) >> 8)
+					bl = uint8(uint32(bl) * 0xffff / uint32(a) >> 8)
+				}
+				putNRGBA(dst.Pix[i:], r, g, bl, a)
+				i += 4
+			}
+		}
+	case *image.Gray:
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				v := src.Pix[src.PixOffset(x, y)]
+				putNRGBA(dst.Pix[i:], v, v, v, 0xff)
+				i += 4
+			}
+		}
+	case *image.Paletted:
+		pal := make([]color.NRGBA, len(src.Palette))
+		for j, c := range src.Palette {
+			pal[j] = color.NRGBAModel.Convert(c).(color.NRGBA)
+		}
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				var c color.NRGBA
+				if idx := int(src.Pix[src.PixOffset(x, y)]); idx < len(pal) {
+					c = pal[idx]
+				}
+				putNRGBA(dst.Pix[i:], c.R, c.G, c.B, c.A)
+				i += 4
+			}
+		}
+	case *image.YCbCr:
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				yi, ci := src.YOffset(x, y), src.COffset(x, y)
+				r, g, bl := color.YCbCrToRGB(src.Y[yi], src.Cb[ci], src.Cr[ci])
+				putNRGBA(dst.Pix[i:], r, g, bl, 0xff)
+				i += 4
+			}
+		}
+	default:
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				c := color.NRGBAModel.Convert(m.At(x, y)).(color.NRGBA)
+				putNRGBA(dst.Pix[i:], c.R, c.G, c.B, c.A)
+				i += 4
+			}
+		}
+	}
+	return dst
+}
+
+// putNRGBA puts the color components into the pixel buffer.
+func putNRGBA(pix []uint8, r, g, b, a uint8) {
+	pix[0], pix[1], pix[2], pix[3] = r, g, b, a
+}
+
+// toNRGBA64 converts the image into NRGBA64 image.
+func toNRGBA64(m image.Image) *image.NRGBA64 {
+	b := m.Bounds()
+	dst := image.NewNRGBA64(b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := color.NRGBA64Model.Convert(m.At(x, y)).(color.NRGBA64)
+			dst.SetNRGBA64(x, y, c)
+		}
+	}
+	return dst
+}
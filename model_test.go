@@ -0,0 +1,63 @@
+package pixmatch
+
+import (
+	"image"
+	"image/color"
+	"reflect"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	r := image.Rect(1, 2, 4, 4)
+	images := map[image.Image]image.Image{
+		image.NewRGBA(r):  &image.NRGBA{},
+		image.NewGray(r):  &image.NRGBA{},
+		image.NewCMYK(r):  &image.NRGBA{},
+		image.NewAlpha(r): &image.NRGBA{},
+		image.NewYCbCr(r, image.YCbCrSubsampleRatio420):  &image.NRGBA{},
+		image.NewPaletted(r, color.Palette{color.White}): &image.NRGBA{},
+		image.NewNRGBA(r):   &image.NRGBA{},
+		image.NewGray16(r):  &image.NRGBA64{},
+		image.NewRGBA64(r):  &image.NRGBA64{},
+		image.NewAlpha16(r): &image.NRGBA64{},
+	}
+	for m, want := range images {
+		got := Normalize(m)
+		if reflect.TypeOf(got) != reflect.TypeOf(want) {
+			t.Errorf("Expected %T got %T", want, got)
+		}
+		if !got.Bounds().Eq(r) {
+			t.Errorf("Expected %v got %v", r, got.Bounds())
+		}
+	}
+}
+
+func TestNormalize_Colors(t *testing.T) {
+	r := image.Rect(0, 0, 2, 2)
+	rgba := image.NewRGBA(r)
+	rgba.SetRGBA(1, 0, color.RGBA{0x40, 0x20, 0x10, 0x80})
+	gray := image.NewGray(r)
+	gray.SetGray(1, 1, color.Gray{0x7f})
+	pal := image.NewPaletted(r, color.Palette{color.Black,
+		color.NRGBA{0xff, 0, 0, 0x80}})
+	pal.SetColorIndex(0, 1, 1)
+	ycbcr := image.NewYCbCr(r, image.YCbCrSubsampleRatio444)
+	for i := range ycbcr.Y {
+		ycbcr.Y[i], ycbcr.Cb[i], ycbcr.Cr[i] = 0x80, 0x40, 0xc0
+	}
+	for _, m := range []image.Image{rgba, gray, pal, ycbcr} {
+		got := Normalize(m)
+		for y := 0; y < 2; y++ {
+			for x := 0; x < 2; x++ {
+				want := color.NRGBAModel.Convert(m.At(x, y)).(color.NRGBA)
+				c := got.At(x, y).(color.NRGBA)
+				if intAbs(int(c.R)-int(want.R)) > 1 ||
+					intAbs(int(c.G)-int(want.G)) > 1 ||
+					intAbs(int(c.B)-int(want.B)) > 1 || c.A != want.A {
+					t.Errorf("%T (%v,%v): expected %v got %v", m, x, y,
+						want, c)
+				}
+			}
+		}
+	}
+}
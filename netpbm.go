@@ -0,0 +1,336 @@
+package pixmatch
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"strconv"
+)
+
+// errNetpbmInvalid occurs when Netpbm data cannot be parsed.
+var errNetpbmInvalid = errors.New("netpbm: invalid format")
+
+// netpbmHeader is the parsed header of PBM, PGM or PPM file.
+type netpbmHeader struct {
+	magic  byte
+	width  int
+	height int
+	maxval int
+}
+
+func init() {
+	for _, f := range []struct{ name, magic string }{
+		{FormatPBM, "P1"}, {FormatPBM, "P4"},
+		{FormatPGM, "P2"}, {FormatPGM, "P5"},
+		{FormatPPM, "P3"}, {FormatPPM, "P6"},
+	} {
+		image.RegisterFormat(f.name, f.magic, decodeNetpbm, decodeNetpbmConfig)
+	}
+}
+
+// isSpace reports whether the byte is a whitespace in the Netpbm header.
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == '\v' ||
+		c == '\f'
+}
+
+// skipSpace skips whitespaces and comments.
+func skipSpace(r *bufio.Reader) error {
+	for {
+		c, err := r.ReadByte()
+		if err != nil {
+			return err
+		}
+		switch {
+		case c == '#':
+			if _, err := r.ReadString('\n'); err != nil {
+				return err
+			}
+		case !isSpace(c):
+			return r.UnreadByte()
+		}
+	}
+}
+
+// readInt reads the next decimal number. A single whitespace after the
+// number is consumed.
+func readInt(r *bufio.Reader) (int, error) {
+	if err := skipSpace(r); err != nil {
+		return 0, err
+	}
+	var buf []byte
+	for {
+		c, err := r.ReadByte()
+		if err == io.EOF && len(buf) > 0 {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+		if isSpace(c) {
+			break
+		}
+		buf = append(buf, c)
+	}
+	n, err := strconv.Atoi(string(buf))
+	if err != nil || n < 0 {
+		return 0, errNetpbmInvalid
+	}
+	return n, nil
+}
+
+// readNetpbmHeader reads the header of Netpbm image.
+func readNetpbmHeader(r *bufio.Reader) (*netpbmHeader, error) {
+	var magic [2]byte
+	if _, err := io.ReadFull(r, magic[:]); err != nil {
+		return nil, err
+	}
+	if magic[0] != 'P' || magic[1] < '1' || magic[1] > '6' {
+		return nil, errNetpbmInvalid
+	}
+	h := &netpbmHeader{magic: magic[1], maxval: 1}
+	var err error
+	if h.width, err = readInt(r); err != nil {
+		return nil, err
+	}
+	if h.height, err = readInt(r); err != nil {
+		return nil, err
+	}
+	if h.magic != '1' && h.magic != '4' {
+		if h.maxval, err = readInt(r); err != nil {
+			return nil, err
+		}
+	}
+	if !validSize(h.width, h.height) || h.maxval == 0 || h.maxval > 0xffff {
+		return nil, errNetpbmInvalid
+	}
+	return h, nil
+}
+
+// model returns the color model of the decoded image.
+func (h *netpbmHeader) model() color.Model {
+	gray := h.magic == '1' || h.magic == '2' || h.magic == '4' || h.magic == '5'
+	switch {
+	case gray && h.maxval > 0xff:
+		return color.Gray16Model
+	case gray:
+		return color.GrayModel
+	case h.maxval > 0xff:
+		return color.RGBA64Model
+	}
+	return color.RGBAModel
+}
+
+// decodeNetpbmConfig returns the color model and dimensions of Netpbm image.
+func decodeNetpbmConfig(r io.Reader) (image.Config, error) {
+	h, err := readNetpbmHeader(bufio.NewReader(r))
+	if err != nil {
+		return image.Config{}, err
+	}
+	return image.Config{
+		ColorModel: h.model(),
+		Width:      h.width,
+		Height:     h.height,
+	}, nil
+}
+
+// decodeNetpbm decodes PBM (P1, P4), PGM (P2, P5) and PPM (P3, P6) images
+// with up to 16 bits per sample.
+// Read more https://netpbm.sourceforge.net/doc/
+func decodeNetpbm(r io.Reader) (image.Image, error) {
+	br := bufio.NewReader(r)
+	h, err := readNetpbmHeader(br)
+	if err != nil {
+		return nil, err
+	}
+
+	rect := image.Rect(0, 0, h.width, h.height)
+	samples := 1
+	if h.magic == '3' || h.magic == '6' {
+		samples = 3
+	}
+	wide := h.maxval > 0xff
+	bpc := 1
+	if wide {
+		bpc = 2
+	}
+	size := h.width * h.height * samples * bpc
+
+	// Binary data is read before allocation, so truncated files cannot
+	// allocate the whole image.
+	var pix []byte
+	if h.magic == '5' || h.magic == '6' {
+		if pix, err = io.ReadAll(io.LimitReader(br, int64(size))); err != nil {
+			return nil, err
+		}
+		if len(pix) < size {
+			return nil, io.ErrUnexpectedEOF
+		}
+	} else {
+		pix = make([]byte, size)
+	}
+
+	switch h.magic {
+	case '1':
+		for i := 0; i < h.width*h.height; i++ {
+			if err := skipSpace(br); err != nil {
+				return nil, err
+			}
+			c, err := br.ReadByte()
+			if err != nil {
+				return nil, err
+			}
+			if c != '0' && c != '1' {
+				return nil, errNetpbmInvalid
+			}
+			pix[i] = bitValue(c == '0')
+		}
+	case '4':
+		row := make([]byte, (h.width+7)/8)
+		for y := 0; y < h.height; y++ {
+			if _, err := io.ReadFull(br, row); err != nil {
+				return nil, err
+			}
+			for x := 0; x < h.width; x++ {
+				pix[y*h.width+x] = bitValue(row[x/8]&(0x80>>(x%8)) == 0)
+			}
+		}
+	case '2', '3':
+		for i := 0; i < h.width*h.height*samples; i++ {
+			v, err := readInt(br)
+			if err != nil {
+				return nil, err
+			}
+			if v > h.maxval {
+				return nil, errNetpbmInvalid
+			}
+			putSample(pix, i, v, h.maxval)
+		}
+	case '5', '6':
+		for i := 0; i < h.width*h.height*samples; i++ {
+			var v int
+			if wide {
+				v = int(pix[i*2])<<8 | int(pix[i*2+1])
+			} else {
+				v = int(pix[i])
+			}
+			if v > h.maxval {
+				return nil, errNetpbmInvalid
+			}
+			putSample(pix, i, v, h.maxval)
+		}
+	}
+
+	switch h.model() {
+	case color.GrayModel:
+		return &image.Gray{Pix: pix, Stride: h.width, Rect: rect}, nil
+	case color.Gray16Model:
+		return &image.Gray16{Pix: pix, Stride: h.width * 2, Rect: rect}, nil
+	}
+	rgba := make([]byte, h.width*h.height*4*bpc)
+	for i := 0; i < h.width*h.height; i++ {
+		copy(rgba[i*4*bpc:], pix[i*3*bpc:(i+1)*3*bpc])
+		for j := 0; j < bpc; j++ {
+			rgba[i*4*bpc+3*bpc+j] = 0xff
+		}
+	}
+	if wide {
+		return &image.RGBA64{Pix: rgba, Stride: h.width * 8, Rect: rect}, nil
+	}
+	return &image.RGBA{Pix: rgba, Stride: h.width * 4, Rect: rect}, nil
+}
+
+// bitValue returns the gray value of PBM bit, where 1 is black.
+func bitValue(white bool) byte {
+	if white {
+		return 0xff
+	}
+	return 0
+}
+
+// putSample scales the value to 8 or 16 bits and puts it into i-th sample
+// of pix.
+func putSample(pix []byte, i, v, maxval int) {
+	if maxval > 0xff {
+		v = v * 0xffff / maxval
+		pix[i*2], pix[i*2+1] = byte(v>>8), byte(v)
+		return
+	}
+	pix[i] = byte(v * 0xff / maxval)
+}
+
+// encodeNetpbm writes the image in binary Netpbm format: PBM, PGM or PPM
+// depending on the format. 16-bit images are written with 16-bit samples
+// to PGM and PPM, alpha channel is dropped.
+func encodeNetpbm(w io.Writer, m image.Image, format string) error {
+	b := m.Bounds()
+	wide := false
+	switch m.(type) {
+	case *image.Gray16, *image.RGBA64, *image.NRGBA64:
+		wide = format != FormatPBM
+	}
+	maxval := 0xff
+	if wide {
+		maxval = 0xffff
+	}
+
+	bw := bufio.NewWriter(w)
+	var err error
+	switch format {
+	case FormatPBM:
+		_, err = fmt.Fprintf(bw, "P4\n%d %d\n", b.Dx(), b.Dy())
+	case FormatPGM:
+		_, err = fmt.Fprintf(bw, "P5\n%d %d\n%d\n", b.Dx(), b.Dy(), maxval)
+	case FormatPPM:
+		_, err = fmt.Fprintf(bw, "P6\n%d %d\n%d\n", b.Dx(), b.Dy(), maxval)
+	default:
+		return ErrUnknownFormat
+	}
+	if err != nil {
+		return err
+	}
+
+	row := make([]byte, 0, b.Dx()*6)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		row = row[:0]
+		if format == FormatPBM {
+			row = row[:(b.Dx()+7)/8]
+			for i := range row {
+				row[i] = 0
+			}
+		}
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := m.At(x, y)
+			switch format {
+			case FormatPBM:
+				if color.GrayModel.Convert(c).(color.Gray).Y < 0x80 {
+					i := x - b.Min.X
+					row[i/8] |= 0x80 >> (i % 8)
+				}
+			case FormatPGM:
+				g := color.Gray16Model.Convert(c).(color.Gray16)
+				row = appendSample(row, uint32(g.Y), wide)
+			case FormatPPM:
+				r, g, b, _ := c.RGBA()
+				row = appendSample(row, r, wide)
+				row = appendSample(row, g, wide)
+				row = appendSample(row, b, wide)
+			}
+		}
+		if _, err := bw.Write(row); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// appendSample appends 16-bit value as 8 or 16-bit sample.
+func appendSample(buf []byte, v uint32, wide bool) []byte {
+	if wide {
+		return append(buf, byte(v>>8), byte(v))
+	}
+	return append(buf, byte(v>>8))
+}
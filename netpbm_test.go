@@ -0,0 +1,77 @@
+package pixmatch
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestNetpbm_ASCII(t *testing.T) {
+	images := map[string]image.Image{
+		"P1\n# bits\n3 1\n101": &image.Gray{
+			Pix: []uint8{0, 0xff, 0}, Stride: 3, Rect: image.Rect(0, 0, 3, 1),
+		},
+		"P2 3 1 15 # gray\n0 15\n5": &image.Gray{
+			Pix: []uint8{0, 0xff, 0x55}, Stride: 3, Rect: image.Rect(0, 0, 3, 1),
+		},
+		"P3\n1 1\n1023\n1023 0 341": &image.RGBA64{
+			Pix:    []uint8{0xff, 0xff, 0, 0, 0x55, 0x55, 0xff, 0xff},
+			Stride: 8, Rect: image.Rect(0, 0, 1, 1),
+		},
+	}
+	for data, want := range images {
+		m, err := decodeNetpbm(bytes.NewBufferString(data))
+		if err != nil {
+			t.Errorf("%q: %v", data, err)
+			continue
+		}
+		if !sameColors(want, m) {
+			t.Errorf("%q: expected %v got %v", data, want, m)
+		}
+	}
+}
+
+func TestNetpbm_RoundTrip(t *testing.T) {
+	m := image.NewRGBA(image.Rect(0, 0, 5, 3))
+	for i := range m.Pix {
+		m.Pix[i] = uint8(i * 17)
+		if i%4 == 3 {
+			m.Pix[i] = 0xff
+		}
+	}
+	if img := roundTrip(t, m, FormatPPM); !sameColors(m, img) {
+		t.Error("Colors of PPM do not match")
+	}
+
+	g := image.NewGray16(image.Rect(0, 0, 4, 2))
+	g.SetGray16(3, 1, color.Gray16{0x1234})
+	img := roundTrip(t, g, FormatPGM)
+	if _, ok := img.Image.(*image.NRGBA64); !ok {
+		t.Errorf("Expected %T got %T", &image.NRGBA64{}, img.Image)
+	}
+	if !sameColors(g, img) {
+		t.Error("Colors of 16-bit PGM do not match")
+	}
+
+	b := image.NewGray(image.Rect(0, 0, 10, 2))
+	b.SetGray(9, 1, color.Gray{0xff})
+	b.SetGray(0, 0, color.Gray{0xff})
+	if img := roundTrip(t, b, FormatPBM); !sameColors(b, img) {
+		t.Error("Colors of PBM do not match")
+	}
+}
+
+func TestNetpbm_Invalid(t *testing.T) {
+	for _, data := range []string{"P5 0 1 255\n", "P2 1 1 255\n256", "P7",
+		"P5 3037000500 3037000500 255\n", "P5 4294967296 4294967296 255\n",
+		"P6 16384 16384 255\n\x00\x00\x00", "P6 -1 1 255\n\x00\x00\x00",
+		"P5 1 1 0\n\x00", "P5 1 1 65536\n\x00\x00",
+		"P1 2 1 1 2", "P1 2 1 1", "P2 1 1 255 x", "P3 1 1 255 1 2",
+		"P4 16 2\n\x00\x00\x00", "P5 2 1 65535\n\x00\x00\x00",
+		"P6 1 1\n255\n\x00\x00", "P5 # comment without end"} {
+		if _, err := decodeNetpbm(bytes.NewBufferString(data)); err == nil {
+			t.Errorf("%q should not be decoded", data)
+		}
+	}
+}
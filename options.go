@@ -1,10 +1,59 @@
 package pixmatch
 
 import (
+	"image"
 	"image/color"
 	"io"
 )
 
+// SizePolicy is the policy to compare images with different dimensions.
+type SizePolicy int
+
+const (
+	// SizeStrict does not compare images with different dimensions,
+	// ErrDimensionsDoNotMatch is returned.
+	SizeStrict SizePolicy = iota
+
+	// SizeOverlap compares the overlapping area of the images. All pixels
+	// outside of it are counted as differences.
+	SizeOverlap
+
+	// SizePad pads the images with PadColor to the same dimensions and
+	// compares them entirely.
+	SizePad
+)
+
+// Anchor is the position of the second image relative to the first one
+// when their dimensions are different.
+type Anchor int
+
+const (
+	// AnchorTopLeft aligns top left corners of the images.
+	AnchorTopLeft Anchor = iota
+
+	// AnchorCenter aligns centers of the images.
+	AnchorCenter
+
+	// AnchorOffset places the top left corner of the second image at
+	// Offset relative to the top left corner of the first one.
+	AnchorOffset
+)
+
+// AADetector is the algorithm to detect anti-aliased pixels.
+type AADetector int
+
+const (
+	// AAPixelmatch detects anti-aliased pixels like pixelmatch.js does: the
+	// pixel has few same neighbors and its darkest or brightest neighbor
+	// lies on the flat area in both images.
+	AAPixelmatch AADetector = iota
+
+	// AAGradient detects anti-aliased pixels on the edges, found with the
+	// gradient of brightness in both images. The pixel is anti-aliased if
+	// its brightness is between the darkest and the brightest neighbors.
+	AAGradient
+)
+
 // Options is the structure that stores the settings for common comparisons.
 type Options struct {
 	// Output is structure where final image will be written.
@@ -14,6 +63,15 @@ type Options struct {
 	// Values range [0, 1.0].
 	Threshold float64
 
+	// Metric is the measure of the difference between colors. Threshold is
+	// normalized by the metric. YIQ is used if it is nil.
+	Metric Metric
+
+	// HighPrecision compares 16 bits per channel of 16-bit images instead
+	// of 8 bits. Threshold keeps its meaning. The output is 16-bit if any
+	// of the images is 16-bit.
+	HighPrecision bool
+
 	// Alpha is the alpha channel factor (multiplier). Values range [0, 1.0].
 	// NOTE it is interesting to experiment with overflow and underflow
 	// ranges.
@@ -25,6 +83,19 @@ type Options struct {
 	// AAColor is the color to mark anti-aliasing pixels.
 	AAColor color.Color
 
+	// AADetector is the algorithm to detect anti-aliased pixels.
+	AADetector AADetector
+
+	// AARadius is the radius of the window around the pixel, which is used
+	// to detect anti-aliasing. The default is 1, 3x3 window, it is used if
+	// the value is not positive.
+	AARadius int
+
+	// AANeighbors is the maximum number of the same neighbors of the
+	// anti-aliased pixel in the window. Used by AAPixelmatch detector. The
+	// default is 2, it is used if the value is not positive.
+	AANeighbors int
+
 	// DiffColor is the color to highlight the differences.
 	DiffColor color.Color
 
@@ -33,25 +104,159 @@ type Options struct {
 	// required.
 	DiffColorAlt color.Color
 
+	// Colormap renders the differences as the heatmap, mapping the magnitude
+	// of the delta to the colormap instead of DiffColor. The magnitude has
+	// the same scale as the threshold, so the delta at the threshold 0.1 is
+	// mapped to 0.1 of the colormap for every metric.
+	Colormap Colormap
+
+	// Faint renders under-threshold deltas faintly with the colormap.
+	// Works only with Colormap.
+	Faint bool
+
+	// Legend adds the legend strip of the colormap at the bottom of the
+	// output. Works only with Colormap.
+	Legend bool
+
+	// Composite writes the composite image of the expected image, the
+	// actual image and the difference to the output instead of the
+	// difference only.
+	Composite Layout
+
+	// Labels adds labels to the panels of the composite image.
+	Labels bool
+
+	// Onion adds the overlay panel of both images blended together (onion
+	// skin) to the composite image.
+	Onion bool
+
 	// DiffMask sets to use mask, renders the differences without the original
 	// image.
 	DiffMask bool
 
 	// KeepEmptyDiff removes empty diff files.
 	KeepEmptyDiff bool
+
+	// Ignore is the list of regions, which are skipped by the comparison.
+	Ignore []Region
+
+	// Include is the list of regions to compare. If it is not empty, all
+	// pixels outside of these regions are skipped by the comparison.
+	Include []Region
+
+	// IgnoreColor is the color to mark skipped pixels.
+	IgnoreColor color.Color
+
+	// Connectivity enables grouping of different pixels into clusters.
+	// Values are 4 or 8 neighbors, 0 disables clustering.
+	Connectivity int
+
+	// ClusterDistance merges clusters when the gap between their bounding
+	// boxes is not greater than this number of pixels. 0 disables merging.
+	ClusterDistance int
+
+	// ClusterColor is the color to draw bounding boxes of the clusters in
+	// the output. Nothing is drawn if it is nil.
+	ClusterColor color.Color
+
+	// SizePolicy is the policy to compare images with different dimensions.
+	SizePolicy SizePolicy
+
+	// Anchor is the position of the second image relative to the first one
+	// when their dimensions are different.
+	Anchor Anchor
+
+	// Offset is the offset of the second image, used with AnchorOffset.
+	Offset image.Point
+
+	// PadColor is the color to pad images with different dimensions.
+	PadColor color.Color
+
+	// ExtraColor is the color to mark different pixels outside of the
+	// overlapping area of images with different dimensions.
+	ExtraColor color.Color
+
+	// Resample is the filter to resample the second image to the dimensions
+	// of the first one, if they are different. SizePolicy is not used then.
+	Resample ResampleFilter
+
+	// AlignRadius is the radius of the search for the global translation of
+	// the second image. If it is positive, images are aligned before the
+	// comparison, see [Image.Align]. The area, which is not covered by the
+	// shifted second image, is padded with PadColor, and its differences are
+	// extra pixels.
+	AlignRadius int
+
+	// ShiftRadius is the radius of the per-pixel shift tolerance. If it is
+	// positive, the different pixel is matching if any pixel of the second
+	// image within the radius is within the threshold, and vice versa.
+	ShiftRadius int
+
+	// MaxDiff is the budget of different pixels. The comparison stops as
+	// soon as the number of different pixels exceeds it and the output is
+	// not rendered, unless RenderExceeded is set. Nil disables the budget,
+	// zero budget fails on the first different pixel.
+	MaxDiff *int
+
+	// MaxDiffPercent is the budget of different pixels in percents of the
+	// compared pixels, like MaxDiff. Nil disables the budget.
+	MaxDiffPercent *float64
+
+	// RenderExceeded compares the images entirely to render the output even
+	// if the budget of different pixels is exceeded.
+	RenderExceeded bool
+
+	// Workers is the number of goroutines comparing bands of rows. 0 uses
+	// runtime.GOMAXPROCS(0) workers.
+	Workers int
+
+	// Progress is called every time a band of rows of the image is compared
+	// with the number of compared rows and the total number of rows. Calls
+	// are serialized, but the rows are compared in arbitrary order.
+	Progress func(done, total int)
 }
 
 // defaultOptions are just default options.
 var defaultOptions = Options{
-	Output:        nil,
-	Threshold:     0.1,
-	Alpha:         0.1,
-	IncludeAA:     false,
-	AAColor:       color.RGBA{0xff, 0xff, 0, 0xff},
-	DiffColor:     color.RGBA{0xff, 0, 0, 0xff},
-	DiffColorAlt:  nil,
-	DiffMask:      false,
-	KeepEmptyDiff: false,
+	Output:          nil,
+	Threshold:       0.1,
+	Metric:          nil,
+	HighPrecision:   false,
+	Alpha:           0.1,
+	IncludeAA:       false,
+	AAColor:         color.RGBA{0xff, 0xff, 0, 0xff},
+	AADetector:      AAPixelmatch,
+	AARadius:        1,
+	AANeighbors:     2,
+	DiffColor:       color.RGBA{0xff, 0, 0, 0xff},
+	DiffColorAlt:    nil,
+	Colormap:        nil,
+	Faint:           false,
+	Legend:          false,
+	Composite:       LayoutNone,
+	Labels:          false,
+	Onion:           false,
+	DiffMask:        false,
+	KeepEmptyDiff:   false,
+	Ignore:          nil,
+	Include:         nil,
+	IgnoreColor:     color.RGBA{0xc0, 0xc0, 0xff, 0xff},
+	Connectivity:    0,
+	ClusterDistance: 0,
+	ClusterColor:    nil,
+	SizePolicy:      SizeStrict,
+	Anchor:          AnchorTopLeft,
+	Offset:          image.Point{},
+	PadColor:        color.RGBA{0, 0, 0, 0},
+	ExtraColor:      color.RGBA{0, 0xff, 0xff, 0xff},
+	Resample:        ResampleNone,
+	AlignRadius:     0,
+	ShiftRadius:     0,
+	MaxDiff:         nil,
+	MaxDiffPercent:  nil,
+	RenderExceeded:  false,
+	Workers:         0,
+	Progress:        nil,
 }
 
 // NewOptions creates a new Options instance. It is possible to use
@@ -59,15 +264,45 @@ var defaultOptions = Options{
 // dependencies whenever possible.
 func NewOptions() *Options {
 	return &Options{
-		Output:        defaultOptions.Output,
-		Threshold:     defaultOptions.Threshold,
-		Alpha:         defaultOptions.Alpha,
-		IncludeAA:     defaultOptions.IncludeAA,
-		AAColor:       defaultOptions.AAColor,
-		DiffColor:     defaultOptions.DiffColor,
-		DiffColorAlt:  defaultOptions.DiffColorAlt,
-		DiffMask:      defaultOptions.DiffMask,
-		KeepEmptyDiff: defaultOptions.KeepEmptyDiff,
+		Output:          defaultOptions.Output,
+		Threshold:       defaultOptions.Threshold,
+		Metric:          defaultOptions.Metric,
+		HighPrecision:   defaultOptions.HighPrecision,
+		Alpha:           defaultOptions.Alpha,
+		IncludeAA:       defaultOptions.IncludeAA,
+		AAColor:         defaultOptions.AAColor,
+		AADetector:      defaultOptions.AADetector,
+		AARadius:        defaultOptions.AARadius,
+		AANeighbors:     defaultOptions.AANeighbors,
+		DiffColor:       defaultOptions.DiffColor,
+		DiffColorAlt:    defaultOptions.DiffColorAlt,
+		Colormap:        defaultOptions.Colormap,
+		Faint:           defaultOptions.Faint,
+		Legend:          defaultOptions.Legend,
+		Composite:       defaultOptions.Composite,
+		Labels:          defaultOptions.Labels,
+		Onion:           defaultOptions.Onion,
+		DiffMask:        defaultOptions.DiffMask,
+		KeepEmptyDiff:   defaultOptions.KeepEmptyDiff,
+		Ignore:          defaultOptions.Ignore,
+		Include:         defaultOptions.Include,
+		IgnoreColor:     defaultOptions.IgnoreColor,
+		Connectivity:    defaultOptions.Connectivity,
+		ClusterDistance: defaultOptions.ClusterDistance,
+		ClusterColor:    defaultOptions.ClusterColor,
+		SizePolicy:      defaultOptions.SizePolicy,
+		Anchor:          defaultOptions.Anchor,
+		Offset:          defaultOptions.Offset,
+		PadColor:        defaultOptions.PadColor,
+		ExtraColor:      defaultOptions.ExtraColor,
+		Resample:        defaultOptions.Resample,
+		AlignRadius:     defaultOptions.AlignRadius,
+		ShiftRadius:     defaultOptions.ShiftRadius,
+		MaxDiff:         defaultOptions.MaxDiff,
+		MaxDiffPercent:  defaultOptions.MaxDiffPercent,
+		RenderExceeded:  defaultOptions.RenderExceeded,
+		Workers:         defaultOptions.Workers,
+		Progress:        defaultOptions.Progress,
 	}
 }
 
@@ -83,6 +318,18 @@ func (opts *Options) SetThreshold(v float64) *Options {
 	return opts
 }
 
+// SetMetric sets metric of the color difference to the options.
+func (opts *Options) SetMetric(v Metric) *Options {
+	opts.Metric = v
+	return opts
+}
+
+// SetHighPrecision sets comparison of 16 bits per channel to the options.
+func (opts *Options) SetHighPrecision(v bool) *Options {
+	opts.HighPrecision = v
+	return opts
+}
+
 // SetAlpha sets alpha to the options.
 func (opts *Options) SetAlpha(v float64) *Options {
 	opts.Alpha = v
@@ -102,6 +349,27 @@ func (opts *Options) SetAAColor(v color.Color) *Options {
 	return opts
 }
 
+// SetAADetector sets the algorithm to detect anti-aliased pixels to the
+// options.
+func (opts *Options) SetAADetector(v AADetector) *Options {
+	opts.AADetector = v
+	return opts
+}
+
+// SetAARadius sets radius of the window to detect anti-aliasing to the
+// options.
+func (opts *Options) SetAARadius(v int) *Options {
+	opts.AARadius = v
+	return opts
+}
+
+// SetAANeighbors sets maximum number of the same neighbors of anti-aliased
+// pixels to the options.
+func (opts *Options) SetAANeighbors(v int) *Options {
+	opts.AANeighbors = v
+	return opts
+}
+
 // SetDiffColor sets color of differences to the options.
 func (opts *Options) SetDiffColor(v color.Color) *Options {
 	opts.DiffColor = v
@@ -114,6 +382,42 @@ func (opts *Options) SetDiffColorAlt(v color.Color) *Options {
 	return opts
 }
 
+// SetColormap sets colormap of the heatmap to the options.
+func (opts *Options) SetColormap(v Colormap) *Options {
+	opts.Colormap = v
+	return opts
+}
+
+// SetFaint sets rendering of under-threshold deltas to the options.
+func (opts *Options) SetFaint(v bool) *Options {
+	opts.Faint = v
+	return opts
+}
+
+// SetLegend sets legend of the colormap to the options.
+func (opts *Options) SetLegend(v bool) *Options {
+	opts.Legend = v
+	return opts
+}
+
+// SetComposite sets layout of the composite image to the options.
+func (opts *Options) SetComposite(v Layout) *Options {
+	opts.Composite = v
+	return opts
+}
+
+// SetLabels sets labels of the composite image to the options.
+func (opts *Options) SetLabels(v bool) *Options {
+	opts.Labels = v
+	return opts
+}
+
+// SetOnion sets overlay panel of the composite image to the options.
+func (opts *Options) SetOnion(v bool) *Options {
+	opts.Onion = v
+	return opts
+}
+
 // SetDiffMask sets difference mask to the options.
 func (opts *Options) SetDiffMask(v bool) *Options {
 	opts.DiffMask = v
@@ -125,3 +429,160 @@ func (opts *Options) SetKeepEmptyDiff(v bool) *Options {
 	opts.KeepEmptyDiff = v
 	return opts
 }
+
+// SetIgnore sets regions to skip to the options.
+func (opts *Options) SetIgnore(v ...Region) *Options {
+	opts.Ignore = v
+	return opts
+}
+
+// SetInclude sets regions to compare to the options.
+func (opts *Options) SetInclude(v ...Region) *Options {
+	opts.Include = v
+	return opts
+}
+
+// SetIgnoreColor sets color of skipped pixels to the options.
+func (opts *Options) SetIgnoreColor(v color.Color) *Options {
+	opts.IgnoreColor = v
+	return opts
+}
+
+// SetConnectivity sets connectivity of the clusters to the options.
+func (opts *Options) SetConnectivity(v int) *Options {
+	opts.Connectivity = v
+	return opts
+}
+
+// SetClusterDistance sets distance to merge clusters to the options.
+func (opts *Options) SetClusterDistance(v int) *Options {
+	opts.ClusterDistance = v
+	return opts
+}
+
+// SetClusterColor sets color of clusters' bounding boxes to the options.
+func (opts *Options) SetClusterColor(v color.Color) *Options {
+	opts.ClusterColor = v
+	return opts
+}
+
+// SetSizePolicy sets policy for different dimensions to the options.
+func (opts *Options) SetSizePolicy(v SizePolicy) *Options {
+	opts.SizePolicy = v
+	return opts
+}
+
+// SetAnchor sets anchor of the second image to the options.
+func (opts *Options) SetAnchor(v Anchor) *Options {
+	opts.Anchor = v
+	return opts
+}
+
+// SetOffset sets offset of the second image to the options. Anchor is set
+// to AnchorOffset.
+func (opts *Options) SetOffset(v image.Point) *Options {
+	opts.Anchor = AnchorOffset
+	opts.Offset = v
+	return opts
+}
+
+// SetPadColor sets color of padding to the options.
+func (opts *Options) SetPadColor(v color.Color) *Options {
+	opts.PadColor = v
+	return opts
+}
+
+// SetExtraColor sets color of different pixels outside of overlapping area
+// to the options.
+func (opts *Options) SetExtraColor(v color.Color) *Options {
+	opts.ExtraColor = v
+	return opts
+}
+
+// Skipped checks that the point is skipped by the comparison: it is inside
+// of any ignored region or outside of all included regions.
+func (opts *Options) Skipped(pt image.Point) bool {
+	if len(opts.Include) > 0 && !inRegions(pt, opts.Include) {
+		return true
+	}
+	return inRegions(pt, opts.Ignore)
+}
+
+// SetResample sets the filter to resample the second image to the options.
+func (opts *Options) SetResample(v ResampleFilter) *Options {
+	opts.Resample = v
+	return opts
+}
+
+// SetAlignRadius sets radius of the search for the translation of the second
+// image to the options.
+func (opts *Options) SetAlignRadius(v int) *Options {
+	opts.AlignRadius = v
+	return opts
+}
+
+// SetShiftRadius sets radius of the per-pixel shift tolerance to the options.
+func (opts *Options) SetShiftRadius(v int) *Options {
+	opts.ShiftRadius = v
+	return opts
+}
+
+// SetMaxDiff sets the budget of different pixels to the options. Negative
+// value disables the budget.
+func (opts *Options) SetMaxDiff(v int) *Options {
+	opts.MaxDiff = nil
+	if v >= 0 {
+		opts.MaxDiff = &v
+	}
+	return opts
+}
+
+// SetMaxDiffPercent sets the budget of different pixels in percents to the
+// options. Negative value disables the budget.
+func (opts *Options) SetMaxDiffPercent(v float64) *Options {
+	opts.MaxDiffPercent = nil
+	if v >= 0 {
+		opts.MaxDiffPercent = &v
+	}
+	return opts
+}
+
+// SetRenderExceeded sets rendering of the output when the budget of
+// different pixels is exceeded to the options.
+func (opts *Options) SetRenderExceeded(v bool) *Options {
+	opts.RenderExceeded = v
+	return opts
+}
+
+// hasBudget reports whether any budget of different pixels is set.
+func (opts *Options) hasBudget() bool {
+	return opts.MaxDiff != nil || opts.MaxDiffPercent != nil
+}
+
+// budget returns the maximum number of different pixels of the total
+// compared pixels or -1 if there is no budget.
+func (opts *Options) budget(total int) int {
+	budget := -1
+	if opts.MaxDiff != nil {
+		budget = intMax(*opts.MaxDiff, 0)
+	}
+	if opts.MaxDiffPercent != nil {
+		n := intMax(int(float64(total)*(*opts.MaxDiffPercent)/100), 0)
+		if budget < 0 || n < budget {
+			budget = n
+		}
+	}
+	return budget
+}
+
+// SetWorkers sets the number of comparing goroutines to the options.
+func (opts *Options) SetWorkers(v int) *Options {
+	opts.Workers = v
+	return opts
+}
+
+// SetProgress sets the callback of the comparison progress to the options.
+func (opts *Options) SetProgress(v func(done, total int)) *Options {
+	opts.Progress = v
+	return opts
+}
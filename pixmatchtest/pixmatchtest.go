@@ -0,0 +1,166 @@
+// Package pixmatchtest provides helpers to compare images with golden files
+// in tests.
+//
+//	func TestRender(t *testing.T) {
+//		got := render()
+//		pixmatchtest.AssertImagesMatch(t, got, "testdata/render.png", nil)
+//	}
+//
+// Golden files are created or regenerated by running tests with
+// -pixmatch.update flag:
+//
+//	go test ./... -pixmatch.update
+//
+// The flag is namespaced, so it does not collide with -update flags of the
+// tested packages or other golden file helpers.
+package pixmatchtest
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dknight/go-pixmatch"
+)
+
+// Update is the value of -pixmatch.update flag. Golden files are written
+// instead of being compared if it is set.
+var Update = flag.Bool("pixmatch.update", false,
+	"Update golden files of pixmatchtest.")
+
+// ArtifactsDir is the directory, where actual and diff images of failed
+// assertions are written. It is taken from PIXMATCH_ARTIFACTS environment
+// variable, the default is pixmatchtest directory in the temporary directory.
+var ArtifactsDir = artifactsDir()
+
+func artifactsDir() string {
+	if dir := os.Getenv("PIXMATCH_ARTIFACTS"); dir != "" {
+		return dir
+	}
+	return filepath.Join(os.TempDir(), "pixmatchtest")
+}
+
+// AssertImagesMatch compares the image with the golden file and reports the
+// failure with statistics of differences. If the budget of differences is set
+// in options, the assertion fails only if it is exceeded. Actual and diff
+// images of the failed assertion are written to [ArtifactsDir]. New golden
+// files are written as PNG images. Returns true if images match.
+func AssertImagesMatch(t testing.TB, got image.Image, goldenPath string,
+	opts *pixmatch.Options) bool {
+	t.Helper()
+	if opts == nil {
+		opts = pixmatch.NewOptions()
+	}
+
+	golden, err := pixmatch.NewImageFromPath(goldenPath)
+	format := pixmatch.FormatPNG
+	if err == nil {
+		format = golden.Format
+	}
+	actual := pixmatch.NewImageFromImage(got, format)
+
+	if *Update {
+		if err := save(actual, goldenPath); err != nil {
+			t.Errorf("cannot update golden file %s: %v", goldenPath, err)
+			return false
+		}
+		t.Logf("updated golden file %s", goldenPath)
+		return true
+	}
+	if err != nil {
+		t.Errorf("cannot read golden file %s: %v\n"+
+			"    actual: %s\n"+
+			"    run tests with -pixmatch.update flag to create golden files",
+			goldenPath, err, artifact(t, actual, goldenPath, "actual"))
+		return false
+	}
+
+	// The diff image is rendered entirely even if the budget is exceeded.
+	var diff bytes.Buffer
+	o := *opts
+	o.Output, o.RenderExceeded = &diff, true
+	res, err := golden.CompareResult(actual, &o)
+	if err != nil {
+		gb, ab := golden.Bounds(), actual.Bounds()
+		t.Errorf("cannot compare image with golden file %s: %v\n"+
+			"    golden: %dx%d, actual: %dx%d\n"+
+			"    actual: %s",
+			goldenPath, err, gb.Dx(), gb.Dy(), ab.Dx(), ab.Dy(),
+			artifact(t, actual, goldenPath, "actual"))
+		return false
+	}
+
+	budget := opts.MaxDiff != nil || opts.MaxDiffPercent != nil
+	if budget && !res.Exceeded || !budget && res.Diff == 0 {
+		return true
+	}
+	metric := opts.Metric
+	if metric == nil {
+		metric = pixmatch.YIQ{}
+	}
+	full := metric.Limit(1) / 100
+	diffPath := artifactPath(t, goldenPath, "diff")
+	if err := writeFile(diffPath, diff.Bytes()); err != nil {
+		diffPath = err.Error()
+	}
+	t.Errorf("image does not match golden file %s\n"+
+		"    different pixels: %d of %d (%.2f%%), anti-aliased: %d\n"+
+		"    bounds of differences: %v, threshold: %g\n"+
+		"    max delta: %.2f%%, mean delta: %.2f%% of the maximum\n"+
+		"    diff: %s\n"+
+		"    actual: %s\n"+
+		"    run tests with -pixmatch.update flag to accept changes",
+		goldenPath, res.Diff, res.Total, res.Percent(), res.AA,
+		res.Bounds, opts.Threshold, res.MaxDelta/full, res.MeanDelta/full,
+		diffPath,
+		artifact(t, actual, goldenPath, "actual"))
+	return false
+}
+
+// artifact writes the image to artifacts of the test. Returns the path of the
+// written image or the error message.
+func artifact(t testing.TB, img *pixmatch.Image, goldenPath,
+	kind string) string {
+	path := artifactPath(t, goldenPath, kind)
+	if err := save(img, path); err != nil {
+		return err.Error()
+	}
+	return path
+}
+
+// artifactPath returns the path of the artifact of the test for the golden
+// file. Subtests are written into subdirectories.
+func artifactPath(t testing.TB, goldenPath, kind string) string {
+	name := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`\:*?"<>| `, r) {
+			return '_'
+		}
+		return r
+	}, t.Name())
+	base := filepath.Base(goldenPath)
+	ext := filepath.Ext(base)
+	return filepath.Join(ArtifactsDir, filepath.FromSlash(name),
+		strings.TrimSuffix(base, ext)+"-"+kind+ext)
+}
+
+// save encodes the image into the file creating all directories.
+func save(img *pixmatch.Image, path string) error {
+	var buf bytes.Buffer
+	if err := img.Save(&buf); err != nil {
+		return fmt.Errorf("cannot encode %s: %w", path, err)
+	}
+	return writeFile(path, buf.Bytes())
+}
+
+// writeFile writes the data into the file creating all directories.
+func writeFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
@@ -0,0 +1,105 @@
+package pixmatchtest
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dknight/go-pixmatch"
+)
+
+// recorder records failures of assertions instead of failing the test.
+type recorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func (r *recorder) Logf(format string, args ...interface{}) {}
+
+func loadImage(t *testing.T, path string) *pixmatch.Image {
+	t.Helper()
+	img, err := pixmatch.NewImageFromPath(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
+
+func TestAssertImagesMatch(t *testing.T) {
+	ArtifactsDir = t.TempDir()
+	golden := "../samples/form-a.png"
+	same := loadImage(t, golden)
+	different := loadImage(t, "../samples/form-b.png")
+
+	rec := &recorder{TB: t}
+	if !AssertImagesMatch(rec, same, golden, nil) || len(rec.errors) != 0 {
+		t.Errorf("Expected match got %v", rec.errors)
+	}
+
+	ok := AssertImagesMatch(rec, different, golden, nil)
+	if ok || len(rec.errors) != 1 {
+		t.Fatalf("Expected 1 error got %v", rec.errors)
+	}
+	for _, s := range []string{"form-a.png", "2909 of 51200 (5.68%)",
+		"anti-aliased: 955", "-pixmatch.update"} {
+		if !strings.Contains(rec.errors[0], s) {
+			t.Errorf("Expected %v in %v", s, rec.errors[0])
+		}
+	}
+	for _, kind := range []string{"diff", "actual"} {
+		path := filepath.Join(ArtifactsDir, t.Name(), "form-a-"+kind+".png")
+		if !strings.Contains(rec.errors[0], path) {
+			t.Errorf("Expected %v in %v", path, rec.errors[0])
+		}
+		if _, err := pixmatch.NewImageFromPath(path); err != nil {
+			t.Error(err)
+		}
+	}
+
+	// The budget of differences is not exceeded.
+	rec.errors = nil
+	opts := pixmatch.NewOptions().SetMaxDiffPercent(10)
+	if !AssertImagesMatch(rec, different, golden, opts) {
+		t.Errorf("Expected match got %v", rec.errors)
+	}
+
+	rec.errors = nil
+	small := loadImage(t, "../samples/gray8-a.png")
+	if AssertImagesMatch(rec, small, golden, nil) ||
+		!strings.Contains(strings.Join(rec.errors, ""), "golden: 200x256") {
+		t.Errorf("Expected dimensions error got %v", rec.errors)
+	}
+}
+
+func TestAssertImagesMatch_Update(t *testing.T) {
+	ArtifactsDir = t.TempDir()
+	golden := filepath.Join(t.TempDir(), "testdata", "form.png")
+	img := loadImage(t, "../samples/form-b.png")
+
+	rec := &recorder{TB: t}
+	if AssertImagesMatch(rec, img, golden, nil) ||
+		!strings.Contains(strings.Join(rec.errors, ""), "-pixmatch.update") {
+		t.Errorf("Expected missing golden error got %v", rec.errors)
+	}
+
+	*Update = true
+	defer func() { *Update = false }()
+	rec.errors = nil
+	if !AssertImagesMatch(rec, img, golden, nil) || len(rec.errors) != 0 {
+		t.Errorf("Expected update got %v", rec.errors)
+	}
+	if _, err := os.Stat(golden); err != nil {
+		t.Error(err)
+	}
+
+	*Update = false
+	if !AssertImagesMatch(rec, img, golden, nil) || len(rec.errors) != 0 {
+		t.Errorf("Expected match got %v", rec.errors)
+	}
+}
@@ -0,0 +1,572 @@
+package pixmatch
+
+import (
+	"bufio"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"hash"
+	"hash/crc32"
+	"image/color"
+	"io"
+)
+
+// Errors of streaming PNG decoding.
+var (
+	errPNGInvalid     = errors.New("png: invalid format")
+	errPNGUnsupported = errors.New("png: unsupported format")
+	errPNGChecksum    = errors.New("png: invalid checksum")
+)
+
+// pngSignature starts every PNG file.
+const pngSignature = "\x89PNG\r\n\x1a\n"
+
+// Limits of dimensions of streamed PNG images. Only a few rows are kept in
+// memory, so the width is limited to keep rows reasonably small. The height
+// is limited by the specification only.
+const (
+	pngMaxWidth  = 1 << 24
+	pngMaxHeight = 1<<31 - 1
+)
+
+// pngMaxChunk is the maximum length of the chunks that are read into memory.
+var pngMaxChunk = map[string]int{"IHDR": 13, "PLTE": 3 * 256, "tRNS": 256}
+
+// PNG color types.
+const (
+	pngGray      = 0
+	pngRGB       = 2
+	pngPalette   = 3
+	pngGrayAlpha = 4
+	pngRGBA      = 6
+)
+
+// PNG filter types.
+const (
+	pngFilterNone = iota
+	pngFilterSub
+	pngFilterUp
+	pngFilterAverage
+	pngFilterPaeth
+)
+
+// pngChunkSize is the maximum size of IDAT chunks written by the encoder.
+const pngChunkSize = 1 << 16
+
+// pngReader decodes non-interlaced PNG images row by row, keeping only two
+// rows in memory. Rows are converted into NRGBA or NRGBA64 pixels, the same
+// way as [Normalize] does.
+type pngReader struct {
+	r         *bufio.Reader
+	width     int
+	height    int
+	depth     int
+	colorType int
+	palette   []color.NRGBA
+	trns      []byte
+	z         io.ReadCloser
+	cur       []byte
+	prev      []byte
+	bpp       int
+
+	// Rest of the current IDAT chunk.
+	left int
+	last bool
+	crc  hash.Hash32
+}
+
+// newPNGReader reads the header of PNG image up to the first IDAT chunk.
+func newPNGReader(r io.Reader) (*pngReader, error) {
+	d := &pngReader{r: bufio.NewReader(r), crc: crc32.NewIEEE()}
+	sig := make([]byte, len(pngSignature))
+	if _, err := io.ReadFull(d.r, sig); err != nil ||
+		string(sig) != pngSignature {
+		return nil, ErrUnsupportedFormat
+	}
+	for {
+		length, typ, err := d.chunkHeader()
+		if err != nil {
+			return nil, err
+		}
+		if typ == "IDAT" {
+			if d.width == 0 && d.height == 0 && d.depth == 0 {
+				return nil, errPNGInvalid
+			}
+			d.left = length
+			break
+		}
+		if typ == "IEND" {
+			return nil, errPNGInvalid
+		}
+		limit, ok := pngMaxChunk[typ]
+		if !ok {
+			// Other chunks are skipped without reading them into memory.
+			if _, err := io.CopyN(d.crc, d.r, int64(length)); err != nil {
+				return nil, errPNGInvalid
+			}
+			if err := d.chunkEnd(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if length > limit {
+			return nil, errPNGInvalid
+		}
+		data := make([]byte, length)
+		if _, err := io.ReadFull(d.r, data); err != nil {
+			return nil, errPNGInvalid
+		}
+		d.crc.Write(data)
+		if err := d.chunkEnd(); err != nil {
+			return nil, err
+		}
+		switch typ {
+		case "IHDR":
+			err = d.parseIHDR(data)
+		case "PLTE":
+			err = d.parsePLTE(data)
+		case "tRNS":
+			err = d.parseTRNS(data)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	z, err := zlib.NewReader(d)
+	if err != nil {
+		return nil, errPNGInvalid
+	}
+	d.z = z
+	bits := d.depth * map[int]int{pngGray: 1, pngRGB: 3, pngPalette: 1,
+		pngGrayAlpha: 2, pngRGBA: 4}[d.colorType]
+	d.bpp = (bits + 7) / 8
+	d.cur = make([]byte, 1+(bits*d.width+7)/8)
+	d.prev = make([]byte, len(d.cur))
+	return d, nil
+}
+
+// chunkHeader reads the length and the type of the next chunk.
+func (d *pngReader) chunkHeader() (int, string, error) {
+	var header [8]byte
+	if _, err := io.ReadFull(d.r, header[:]); err != nil {
+		return 0, "", errPNGInvalid
+	}
+	length := binary.BigEndian.Uint32(header[:4])
+	if length > 1<<31-1 {
+		return 0, "", errPNGInvalid
+	}
+	d.crc.Reset()
+	d.crc.Write(header[4:])
+	return int(length), string(header[4:]), nil
+}
+
+// chunkEnd reads and verifies the checksum of the chunk.
+func (d *pngReader) chunkEnd() error {
+	var sum [4]byte
+	if _, err := io.ReadFull(d.r, sum[:]); err != nil {
+		return errPNGInvalid
+	}
+	if binary.BigEndian.Uint32(sum[:]) != d.crc.Sum32() {
+		return errPNGChecksum
+	}
+	return nil
+}
+
+func (d *pngReader) parseIHDR(data []byte) error {
+	if len(data) != 13 {
+		return errPNGInvalid
+	}
+	d.width = int(binary.BigEndian.Uint32(data[0:4]))
+	d.height = int(binary.BigEndian.Uint32(data[4:8]))
+	d.depth = int(data[8])
+	d.colorType = int(data[9])
+	if d.width <= 0 || d.width > pngMaxWidth || d.height <= 0 ||
+		d.height > pngMaxHeight {
+		return errPNGInvalid
+	}
+	if data[10] != 0 || data[11] != 0 {
+		return errPNGInvalid
+	}
+	if data[12] != 0 {
+		// Interlaced images cannot be decoded row by row.
+		return errPNGUnsupported
+	}
+	valid := map[int][]int{
+		pngGray:      {1, 2, 4, 8, 16},
+		pngRGB:       {8, 16},
+		pngPalette:   {1, 2, 4, 8},
+		pngGrayAlpha: {8, 16},
+		pngRGBA:      {8, 16},
+	}
+	for _, depth := range valid[d.colorType] {
+		if depth == d.depth {
+			return nil
+		}
+	}
+	return errPNGInvalid
+}
+
+func (d *pngReader) parsePLTE(data []byte) error {
+	if len(data)%3 != 0 || len(data) > 256*3 {
+		return errPNGInvalid
+	}
+	d.palette = make([]color.NRGBA, len(data)/3)
+	for i := range d.palette {
+		d.palette[i] = color.NRGBA{data[i*3], data[i*3+1], data[i*3+2], 0xff}
+	}
+	return nil
+}
+
+func (d *pngReader) parseTRNS(data []byte) error {
+	if d.colorType == pngPalette {
+		if len(data) > len(d.palette) {
+			return errPNGInvalid
+		}
+		for i, a := range data {
+			d.palette[i].A = a
+		}
+		return nil
+	}
+	d.trns = data
+	return nil
+}
+
+// Read reads the image data from the sequence of IDAT chunks.
+func (d *pngReader) Read(p []byte) (int, error) {
+	for d.left == 0 {
+		if d.last {
+			return 0, io.EOF
+		}
+		if err := d.chunkEnd(); err != nil {
+			return 0, err
+		}
+		length, typ, err := d.chunkHeader()
+		if err != nil {
+			return 0, err
+		}
+		if typ != "IDAT" {
+			d.last = true
+			return 0, io.EOF
+		}
+		d.left = length
+	}
+	if len(p) > d.left {
+		p = p[:d.left]
+	}
+	n, err := d.r.Read(p)
+	d.crc.Write(p[:n])
+	d.left -= n
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+// wide reports whether rows are decoded into NRGBA64 pixels.
+func (d *pngReader) wide() bool {
+	return d.depth == 16
+}
+
+// rowLen is the length of the decoded row in bytes.
+func (d *pngReader) rowLen() int {
+	if d.wide() {
+		return d.width * 8
+	}
+	return d.width * 4
+}
+
+// readRow decodes the next row into dst.
+func (d *pngReader) readRow(dst []byte) error {
+	d.prev, d.cur = d.cur, d.prev
+	if _, err := io.ReadFull(d.z, d.cur); err != nil {
+		return errPNGInvalid
+	}
+	if err := unfilter(d.cur, d.prev, d.bpp); err != nil {
+		return err
+	}
+	return d.convert(dst, d.cur[1:])
+}
+
+// convert converts the unfiltered row into NRGBA or NRGBA64 pixels.
+func (d *pngReader) convert(dst, row []byte) error {
+	switch {
+	case d.depth < 8:
+		mask := byte(1<<d.depth - 1)
+		for x := 0; x < d.width; x++ {
+			bit := x * d.depth
+			v := row[bit/8] >> (8 - d.depth - bit%8) & mask
+			px := dst[x*4 : x*4+4]
+			if d.colorType == pngPalette {
+				if int(v) >= len(d.palette) {
+					return errPNGInvalid
+				}
+				c := d.palette[v]
+				px[0], px[1], px[2], px[3] = c.R, c.G, c.B, c.A
+				continue
+			}
+			g := v * (0xff / mask)
+			px[0], px[1], px[2], px[3] = g, g, g, 0xff
+			if len(d.trns) == 2 && d.trns[1]&mask == v {
+				px[3] = 0
+			}
+		}
+	case d.depth == 8:
+		for x := 0; x < d.width; x++ {
+			px := dst[x*4 : x*4+4]
+			switch d.colorType {
+			case pngGray:
+				g := row[x]
+				px[0], px[1], px[2], px[3] = g, g, g, 0xff
+				if len(d.trns) == 2 && d.trns[1] == g {
+					px[3] = 0
+				}
+			case pngRGB:
+				s := row[x*3 : x*3+3]
+				px[0], px[1], px[2], px[3] = s[0], s[1], s[2], 0xff
+				if len(d.trns) == 6 && d.trns[1] == s[0] &&
+					d.trns[3] == s[1] && d.trns[5] == s[2] {
+					px[3] = 0
+				}
+			case pngPalette:
+				if int(row[x]) >= len(d.palette) {
+					return errPNGInvalid
+				}
+				c := d.palette[row[x]]
+				px[0], px[1], px[2], px[3] = c.R, c.G, c.B, c.A
+			case pngGrayAlpha:
+				g := row[x*2]
+				px[0], px[1], px[2], px[3] = g, g, g, row[x*2+1]
+			case pngRGBA:
+				copy(px, row[x*4:x*4+4])
+			}
+		}
+	default:
+		for x := 0; x < d.width; x++ {
+			px := dst[x*8 : x*8+8]
+			switch d.colorType {
+			case pngGray:
+				s := row[x*2 : x*2+2]
+				copy(px[0:], s)
+				copy(px[2:], s)
+				copy(px[4:], s)
+				px[6], px[7] = 0xff, 0xff
+				if len(d.trns) == 2 && d.trns[0] == s[0] && d.trns[1] == s[1] {
+					px[6], px[7] = 0, 0
+				}
+			case pngRGB:
+				s := row[x*6 : x*6+6]
+				copy(px, s)
+				px[6], px[7] = 0xff, 0xff
+				if len(d.trns) == 6 && string(d.trns) == string(s) {
+					px[6], px[7] = 0, 0
+				}
+			case pngGrayAlpha:
+				s := row[x*4 : x*4+4]
+				copy(px[0:], s[:2])
+				copy(px[2:], s[:2])
+				copy(px[4:], s[:2])
+				copy(px[6:], s[2:])
+			case pngRGBA:
+				copy(px, row[x*8:x*8+8])
+			}
+		}
+	}
+	return nil
+}
+
+// Close releases the decompressor of the reader.
+func (d *pngReader) Close() error {
+	return d.z.Close()
+}
+
+// unfilter reverses the filter of the row in place. The first byte of the
+// rows is the filter type.
+func unfilter(cur, prev []byte, bpp int) error {
+	filter, cdat, pdat := cur[0], cur[1:], prev[1:]
+	switch filter {
+	case pngFilterNone:
+	case pngFilterSub:
+		for i := bpp; i < len(cdat); i++ {
+			cdat[i] += cdat[i-bpp]
+		}
+	case pngFilterUp:
+		for i := range cdat {
+			cdat[i] += pdat[i]
+		}
+	case pngFilterAverage:
+		for i := range cdat {
+			left := 0
+			if i >= bpp {
+				left = int(cdat[i-bpp])
+			}
+			cdat[i] += byte((left + int(pdat[i])) / 2)
+		}
+	case pngFilterPaeth:
+		for i := range cdat {
+			var a, c byte
+			if i >= bpp {
+				a, c = cdat[i-bpp], pdat[i-bpp]
+			}
+			cdat[i] += paeth(a, pdat[i], c)
+		}
+	default:
+		return errPNGInvalid
+	}
+	return nil
+}
+
+// paeth is the Paeth predictor of the left, upper and upper left bytes.
+func paeth(a, b, c byte) byte {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := intAbs(p-int(a)), intAbs(p-int(b)), intAbs(p-int(c))
+	if pa <= pb && pa <= pc {
+		return a
+	}
+	if pb <= pc {
+		return b
+	}
+	return c
+}
+
+// pngWriter encodes NRGBA or NRGBA64 image row by row. Compressed data is
+// written in chunks of limited size, so the whole image is never kept in
+// memory.
+type pngWriter struct {
+	w     io.Writer
+	z     *zlib.Writer
+	buf   []byte
+	prev  []byte
+	cur   []byte
+	trial [5][]byte
+	bpp   int
+}
+
+// newPNGWriter writes the header of the image with the given dimensions.
+// The image has 16 bits per channel if wide is true.
+func newPNGWriter(w io.Writer, width, height int, wide bool) (*pngWriter, error) {
+	e := &pngWriter{w: w, bpp: 4}
+	depth := byte(8)
+	if wide {
+		depth, e.bpp = 16, 8
+	}
+	if _, err := io.WriteString(w, pngSignature); err != nil {
+		return nil, err
+	}
+	ihdr := make([]byte, 13)
+	binary.BigEndian.PutUint32(ihdr[0:4], uint32(width))
+	binary.BigEndian.PutUint32(ihdr[4:8], uint32(height))
+	ihdr[8], ihdr[9] = depth, pngRGBA
+	if err := e.writeChunk("IHDR", ihdr); err != nil {
+		return nil, err
+	}
+	e.z = zlib.NewWriter(e)
+	e.prev = make([]byte, 1+width*e.bpp)
+	e.cur = make([]byte, len(e.prev))
+	for i := range e.trial {
+		e.trial[i] = make([]byte, len(e.prev))
+	}
+	return e, nil
+}
+
+// writeChunk writes the chunk with the checksum.
+func (e *pngWriter) writeChunk(typ string, data []byte) error {
+	var header [8]byte
+	binary.BigEndian.PutUint32(header[:4], uint32(len(data)))
+	copy(header[4:], typ)
+	crc := crc32.NewIEEE()
+	crc.Write(header[4:])
+	crc.Write(data)
+	var sum [4]byte
+	binary.BigEndian.PutUint32(sum[:], crc.Sum32())
+	for _, b := range [][]byte{header[:], data, sum[:]} {
+		if _, err := e.w.Write(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Write collects the compressed data into IDAT chunks.
+func (e *pngWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		m := intMin(len(p), pngChunkSize-len(e.buf))
+		e.buf = append(e.buf, p[:m]...)
+		p = p[m:]
+		if len(e.buf) == pngChunkSize {
+			if err := e.flush(); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return n, nil
+}
+
+// flush writes the collected data as IDAT chunk.
+func (e *pngWriter) flush() error {
+	if len(e.buf) == 0 {
+		return nil
+	}
+	err := e.writeChunk("IDAT", e.buf)
+	e.buf = e.buf[:0]
+	return err
+}
+
+// writeRow filters and compresses the row of NRGBA or NRGBA64 pixels.
+func (e *pngWriter) writeRow(row []byte) error {
+	copy(e.cur[1:], row)
+	_, err := e.z.Write(e.filter())
+	e.prev, e.cur = e.cur, e.prev
+	return err
+}
+
+// filter chooses the filter with the minimum sum of absolute differences,
+// like the standard library encoder does.
+func (e *pngWriter) filter() []byte {
+	cdat, pdat := e.cur[1:], e.prev[1:]
+	best, bestSum := 0, -1
+	for f := range e.trial {
+		t := e.trial[f]
+		t[0] = byte(f)
+		tdat := t[1:]
+		for i := range cdat {
+			var a, b, c byte
+			if i >= e.bpp {
+				a, c = cdat[i-e.bpp], pdat[i-e.bpp]
+			}
+			b = pdat[i]
+			switch f {
+			case pngFilterNone:
+				tdat[i] = cdat[i]
+			case pngFilterSub:
+				tdat[i] = cdat[i] - a
+			case pngFilterUp:
+				tdat[i] = cdat[i] - b
+			case pngFilterAverage:
+				tdat[i] = cdat[i] - byte((int(a)+int(b))/2)
+			case pngFilterPaeth:
+				tdat[i] = cdat[i] - paeth(a, b, c)
+			}
+		}
+		sum := 0
+		for _, v := range tdat {
+			sum += intAbs(int(int8(v)))
+		}
+		if bestSum < 0 || sum < bestSum {
+			best, bestSum = f, sum
+		}
+	}
+	return e.trial[best]
+}
+
+// Close finishes the compressed data and writes the end of the image.
+func (e *pngWriter) Close() error {
+	if err := e.z.Close(); err != nil {
+		return err
+	}
+	if err := e.flush(); err != nil {
+		return err
+	}
+	return e.writeChunk("IEND", nil)
+}
@@ -0,0 +1,193 @@
+package pixmatch
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// readStream decodes all rows of PNG image with the streaming reader.
+func readStream(t *testing.T, data []byte) image.Image {
+	t.Helper()
+	d, err := newPNGReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	r := image.Rect(0, 0, d.width, d.height)
+	var m image.Image
+	var pix []byte
+	if d.wide() {
+		m64 := image.NewNRGBA64(r)
+		m, pix = m64, m64.Pix
+	} else {
+		m8 := image.NewNRGBA(r)
+		m, pix = m8, m8.Pix
+	}
+	for y := 0; y < d.height; y++ {
+		if err := d.readRow(pix[y*d.rowLen() : (y+1)*d.rowLen()]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return m
+}
+
+func TestPNGReader(t *testing.T) {
+	paths, _ := filepath.Glob("./samples/*.png")
+	models, _ := filepath.Glob("./samples/models/*.png")
+	paths = append(paths, models...)
+
+	// Palettes of 1, 2 and 4 bits with transparency.
+	for _, n := range []int{2, 4, 16} {
+		p := make(color.Palette, n)
+		for i := range p {
+			p[i] = color.NRGBA{uint8(i * 16), 0x80, uint8(0xff - i), uint8(i * 15)}
+		}
+		m := image.NewPaletted(image.Rect(0, 0, 13, 7), p)
+		for i := range m.Pix {
+			m.Pix[i] = uint8(i % n)
+		}
+		var buf bytes.Buffer
+		png.Encode(&buf, m)
+		path := filepath.Join(t.TempDir(), "palette.png")
+		os.WriteFile(path, buf.Bytes(), 0644)
+		paths = append(paths, path)
+	}
+
+	for _, path := range paths {
+		if filepath.Base(path) == "corrupted.png" {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := readStream(t, data)
+		if !sameColors(Normalize(want), got) {
+			t.Errorf("%v decoded incorrectly", path)
+		}
+	}
+}
+
+func TestPNGReader_Ancillary(t *testing.T) {
+	data, err := os.ReadFile("./samples/form-a.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := png.Decode(bytes.NewReader(data))
+
+	// Large tEXt chunk after the header is skipped.
+	var buf bytes.Buffer
+	e := &pngWriter{w: &buf}
+	ihdr := len(pngSignature) + 8 + 13 + 4
+	buf.Write(data[:ihdr])
+	e.writeChunk("tEXt", append([]byte("Comment\x00"),
+		bytes.Repeat([]byte("x"), 1<<20)...))
+	buf.Write(data[ihdr:])
+	if got := readStream(t, buf.Bytes()); !sameColors(Normalize(want), got) {
+		t.Error("Image with tEXt chunk decoded incorrectly")
+	}
+
+	// Checksum of the skipped chunk is verified.
+	data = buf.Bytes()
+	data[ihdr+20] ^= 0xff
+	if _, err := newPNGReader(bytes.NewReader(data)); err != errPNGChecksum {
+		t.Errorf("Expected %v got %v", errPNGChecksum, err)
+	}
+}
+
+func TestPNGReader_Errors(t *testing.T) {
+	if _, err := newPNGReader(bytes.NewReader([]byte("GIF89a"))); err !=
+		ErrUnsupportedFormat {
+		t.Errorf("Expected %v got %v", ErrUnsupportedFormat, err)
+	}
+
+	// Interlaced images cannot be streamed.
+	var buf bytes.Buffer
+	buf.WriteString(pngSignature)
+	e := &pngWriter{w: &buf}
+	e.writeChunk("IHDR", []byte{0, 0, 0, 1, 0, 0, 0, 1, 8, pngRGBA, 0, 0, 1})
+	if _, err := newPNGReader(&buf); err != errPNGUnsupported {
+		t.Errorf("Expected %v got %v", errPNGUnsupported, err)
+	}
+
+	// Zero and huge dimensions are rejected before allocation of rows.
+	for _, size := range [][8]byte{
+		{0, 0, 0, 0, 0, 0, 0, 1},
+		{0, 0, 0, 1, 0, 0, 0, 0},
+		{0xff, 0xff, 0xff, 0xff, 0, 0, 0, 1},
+		{0x7f, 0xff, 0xff, 0xff, 0, 0, 0, 1},
+		{0, 0, 0, 1, 0x80, 0, 0, 0},
+	} {
+		buf.Reset()
+		buf.WriteString(pngSignature)
+		e.writeChunk("IHDR", append(size[:], 16, pngRGBA, 0, 0, 0))
+		if _, err := newPNGReader(&buf); err != errPNGInvalid {
+			t.Errorf("%v: expected %v got %v", size, errPNGInvalid, err)
+		}
+	}
+
+	// Huge chunks are not read into memory.
+	for _, typ := range []string{"IHDR", "tEXt"} {
+		buf.Reset()
+		buf.WriteString(pngSignature)
+		buf.Write([]byte{0x7f, 0xff, 0xff, 0xff})
+		buf.WriteString(typ)
+		buf.WriteString("Comment\x00")
+		if _, err := newPNGReader(&buf); err != errPNGInvalid {
+			t.Errorf("%v: expected %v got %v", typ, errPNGInvalid, err)
+		}
+	}
+
+	data, _ := os.ReadFile("./samples/form-a.png")
+	data[len(pngSignature)+10] ^= 0xff
+	if _, err := newPNGReader(bytes.NewReader(data)); err != errPNGChecksum {
+		t.Errorf("Expected %v got %v", errPNGChecksum, err)
+	}
+}
+
+func TestPNGWriter(t *testing.T) {
+	tests := []image.Image{testPattern(37, 23), image.NewNRGBA64(image.Rect(0, 0, 300, 300))}
+	m64 := tests[1].(*image.NRGBA64)
+	for i := range m64.Pix {
+		m64.Pix[i] = uint8(i * 7 % 253)
+	}
+	for _, m := range tests {
+		var buf bytes.Buffer
+		_, wide := m.(*image.NRGBA64)
+		e, err := newPNGWriter(&buf, m.Bounds().Dx(), m.Bounds().Dy(), wide)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for y := 0; y < m.Bounds().Dy(); y++ {
+			var row []byte
+			switch m := m.(type) {
+			case *image.NRGBA:
+				row = m.Pix[m.PixOffset(0, y):m.PixOffset(0, y+1)]
+			case *image.NRGBA64:
+				row = m.Pix[m.PixOffset(0, y):m.PixOffset(0, y+1)]
+			}
+			if err := e.writeRow(row); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := e.Close(); err != nil {
+			t.Fatal(err)
+		}
+		got, err := png.Decode(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !sameColors(m, got) {
+			t.Errorf("%T encoded incorrectly", m)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package pixmatch
+
+import "image"
+
+// Region is an area of the image. Regions are used to ignore some parts of
+// the images or to compare only the given parts of them.
+type Region interface {
+	// Contains reports whether the point is inside the region.
+	Contains(pt image.Point) bool
+}
+
+// Rect is the rectangular region.
+type Rect image.Rectangle
+
+// NewRect creates a new rectangular region. It is a shorthand for
+// Rect(image.Rect(x0, y0, x1, y1)).
+func NewRect(x0, y0, x1, y1 int) Rect {
+	return Rect(image.Rect(x0, y0, x1, y1))
+}
+
+// Contains reports whether the point is inside the rectangle.
+func (r Rect) Contains(pt image.Point) bool {
+	return pt.In(image.Rectangle(r))
+}
+
+// Polygon is the region bounded by the closed polyline of the vertices.
+// The pixel belongs to the polygon if its center is inside the polygon.
+type Polygon []image.Point
+
+// Contains reports whether the point is inside the polygon. Ray casting
+// algorithm is used: https://en.wikipedia.org/wiki/Point_in_polygon
+func (p Polygon) Contains(pt image.Point) bool {
+	if len(p) < 3 {
+		return false
+	}
+	x, y := float64(pt.X)+0.5, float64(pt.Y)+0.5
+	in := false
+	for i, j := 0, len(p)-1; i < len(p); j, i = i, i+1 {
+		xi, yi := float64(p[i].X), float64(p[i].Y)
+		xj, yj := float64(p[j].X), float64(p[j].Y)
+		if (yi > y) != (yj > y) && x < (xj-xi)*(y-yi)/(yj-yi)+xi {
+			in = !in
+		}
+	}
+	return in
+}
+
+// Mask is the bitmap region. Every pixel of the mask image with non-zero
+// alpha channel belongs to the region. Usually [image.Alpha] is used as
+// mask, but any image will fit.
+type Mask struct {
+	image.Image
+}
+
+// Contains reports whether the point is inside the mask.
+func (m Mask) Contains(pt image.Point) bool {
+	if m.Image == nil || !pt.In(m.Bounds()) {
+		return false
+	}
+	_, _, _, a := m.At(pt.X, pt.Y).RGBA()
+	return a > 0
+}
+
+// inRegions checks that the point belongs to any of the regions.
+func inRegions(pt image.Point, regions []Region) bool {
+	for _, r := range regions {
+		if r.Contains(pt) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,118 @@
+package pixmatch
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+func TestRectContains(t *testing.T) {
+	r := NewRect(10, 10, 20, 20)
+	pairs := map[image.Point]bool{
+		{10, 10}: true,
+		{19, 19}: true,
+		{20, 20}: false,
+		{5, 15}:  false,
+	}
+	for pt, want := range pairs {
+		if res := r.Contains(pt); res != want {
+			t.Errorf("%v: expected %v got %v", pt, want, res)
+		}
+	}
+}
+
+func TestPolygonContains(t *testing.T) {
+	// Right triangle with the right angle at the origin.
+	p := Polygon{{0, 0}, {10, 0}, {0, 10}}
+	pairs := map[image.Point]bool{
+		{0, 0}:   true,
+		{2, 2}:   true,
+		{8, 8}:   false,
+		{-1, 1}:  false,
+		{4, 4}:   true,
+		{10, 10}: false,
+	}
+	for pt, want := range pairs {
+		if res := p.Contains(pt); res != want {
+			t.Errorf("%v: expected %v got %v", pt, want, res)
+		}
+	}
+
+	if (Polygon{{0, 0}, {10, 10}}).Contains(image.Pt(0, 0)) {
+		t.Error("Degenerate polygon should not contain points")
+	}
+}
+
+func TestMaskContains(t *testing.T) {
+	alpha := image.NewAlpha(image.Rect(0, 0, 4, 4))
+	alpha.SetAlpha(1, 2, color.Alpha{0xff})
+	m := Mask{alpha}
+	pairs := map[image.Point]bool{
+		{1, 2}: true,
+		{2, 1}: false,
+		{9, 9}: false,
+	}
+	for pt, want := range pairs {
+		if res := m.Contains(pt); res != want {
+			t.Errorf("%v: expected %v got %v", pt, want, res)
+		}
+	}
+	if (Mask{}).Contains(image.Pt(0, 0)) {
+		t.Error("Empty mask should not contain points")
+	}
+}
+
+func TestCompare_Ignore(t *testing.T) {
+	img1, _ := NewImageFromPath("./samples/form-a.png")
+	img2, _ := NewImageFromPath("./samples/form-b.png")
+	full, err := img1.CompareResult(img2, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts := NewOptions().SetIgnore(Rect(full.Bounds))
+	res, err := img1.CompareResult(img2, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Diff != 0 {
+		t.Errorf("Expected %v got %v", 0, res.Diff)
+	}
+	want := full.Bounds.Dx() * full.Bounds.Dy()
+	if res.Ignored != want {
+		t.Errorf("Expected %v got %v", want, res.Ignored)
+	}
+	if res.Total != img1.Size()-want {
+		t.Errorf("Expected %v got %v", img1.Size()-want, res.Total)
+	}
+}
+
+func TestCompare_Include(t *testing.T) {
+	img1, _ := NewImageFromPath("./samples/form-a.png")
+	img2, _ := NewImageFromPath("./samples/form-b.png")
+	full, err := img1.CompareResult(img2, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	opts := NewOptions().SetInclude(Rect(full.Bounds)).SetOutput(&buf)
+	res, err := img1.CompareResult(img2, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Diff != full.Diff {
+		t.Errorf("Expected %v got %v", full.Diff, res.Diff)
+	}
+
+	out, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := color.RGBAModel.Convert(opts.IgnoreColor)
+	if c := color.RGBAModel.Convert(out.At(0, 0)); c != want {
+		t.Errorf("Expected %v got %v", want, c)
+	}
+}
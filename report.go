@@ -0,0 +1,278 @@
+package pixmatch
+
+import (
+	"bytes"
+	"encoding/base64"
+	"html/template"
+	"image/png"
+	"io"
+	"os"
+)
+
+// HTMLReport renders results of comparisons as the self-contained HTML page.
+// Images are embedded into the page, so it can be archived or shared as a
+// single file.
+type HTMLReport struct {
+	// Title is the title of the page.
+	Title string
+
+	// ThumbSize is the maximum width and height of thumbnails in pixels.
+	ThumbSize int
+}
+
+// NewHTMLReport creates a new HTML report with default settings.
+func NewHTMLReport() *HTMLReport {
+	return &HTMLReport{Title: "pixmatch report", ThumbSize: 120}
+}
+
+// SetTitle sets the title of the page to the report.
+func (r *HTMLReport) SetTitle(v string) *HTMLReport {
+	r.Title = v
+	return r
+}
+
+// SetThumbSize sets the maximum size of thumbnails to the report.
+func (r *HTMLReport) SetThumbSize(v int) *HTMLReport {
+	r.ThumbSize = v
+	return r
+}
+
+// reportImage is the image embedded into the report.
+type reportImage struct {
+	Thumb template.URL
+	Full  string
+}
+
+// reportPair is the pair of images rendered in the report.
+type reportPair struct {
+	*PairResult
+	Percent                 float64
+	Expected, Actual, Diffs *reportImage
+}
+
+// Write renders the report of pairs, for example returned by [Batch.Run], to
+// the writer. Images which cannot be read are left out of the report.
+func (r *HTMLReport) Write(w io.Writer, pairs []*PairResult) error {
+	data := struct {
+		Title    string
+		Statuses []PairStatus
+		Counts   map[PairStatus]int
+		Pairs    []*reportPair
+	}{
+		Title: r.Title,
+		Statuses: []PairStatus{PairPassed, PairFailed, PairMissing, PairExtra,
+			PairError, PairRecorded},
+		Counts: make(map[PairStatus]int),
+	}
+	for _, pair := range pairs {
+		p := &reportPair{
+			PairResult: pair,
+			Percent:    -1,
+			Expected:   r.embed(pair.Expected),
+			Actual:     r.embed(pair.Actual),
+			Diffs:      r.embed(pair.Diff),
+		}
+		if pair.Result != nil {
+			p.Percent = pair.Result.Percent()
+		}
+		data.Counts[pair.Status]++
+		data.Pairs = append(data.Pairs, p)
+	}
+	return reportTemplate.Execute(w, data)
+}
+
+// embed reads the image and encodes it and its thumbnail as data URLs. Images
+// in formats, which browsers do not display, are converted into PNG.
+func (r *HTMLReport) embed(path string) *reportImage {
+	if path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	img := NewImage(0, 0, DefaultFormat)
+	if err := img.Load(bytes.NewReader(data)); err != nil || img.Empty() {
+		return nil
+	}
+
+	res := &reportImage{}
+	switch img.Format {
+	case FormatPNG, FormatGIF, FormatJPEG, FormatBMP, FormatWebP:
+		res.Full = dataURL(img.Format, data)
+	default:
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img.Image); err != nil {
+			return nil
+		}
+		res.Full = dataURL(FormatPNG, buf.Bytes())
+	}
+
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	if size := r.ThumbSize; size > 0 && intMax(w, h) > size {
+		if w >= h {
+			w, h = size, intMax(h*size/w, 1)
+		} else {
+			w, h = intMax(w*size/h, 1), size
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf,
+		img.Resize(w, h, ResampleBilinear).Image); err != nil {
+		return nil
+	}
+	res.Thumb = template.URL(dataURL(FormatPNG, buf.Bytes()))
+	return res
+}
+
+// dataURL encodes the image data of the format as data URL.
+func dataURL(format string, data []byte) string {
+	return "data:image/" + format + ";base64," +
+		base64.StdEncoding.EncodeToString(data)
+}
+
+var reportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+body { font: 14px/1.4 sans-serif; margin: 1em 2em; color: #222; }
+table { border-collapse: collapse; width: 100%; }
+th, td { border-bottom: 1px solid #ddd; padding: 6px; text-align: left; vertical-align: middle; }
+th.sortable { cursor: pointer; user-select: none; }
+tbody tr { cursor: pointer; }
+tbody tr:hover { background: #f4f7fb; }
+td.thumb img { display: block; max-width: 100%; background: repeating-conic-gradient(#ccc 0 25%, #fff 0 50%) 0 0 / 16px 16px; }
+.status { font-weight: bold; text-transform: uppercase; font-size: 12px; }
+.passed .status, .recorded .status { color: #1a7f37; }
+.failed .status, .error .status { color: #cf222e; }
+.missing .status, .extra .status { color: #9a6700; }
+.filters label { margin-right: 1em; }
+.hidden { display: none; }
+#viewer { position: fixed; inset: 0; background: rgba(0, 0, 0, .85); color: #fff; overflow: auto; padding: 1em 2em; }
+#viewer button { margin-right: .5em; }
+#stage { position: relative; display: inline-block; margin-top: 1em; cursor: ew-resize; touch-action: none; }
+#stage img { display: block; max-width: 90vw; max-height: 80vh; }
+#stage #actual { position: absolute; top: 0; left: 0; width: 100%; height: 100%; }
+#stage #handle { position: absolute; top: 0; bottom: 0; width: 2px; background: #f0f; pointer-events: none; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p class="filters">
+{{- range $status := .Statuses}}
+<label><input type="checkbox" value="{{$status}}" checked> {{$status}} ({{index $.Counts $status}})</label>
+{{- end}}
+</p>
+<table>
+<thead>
+<tr>
+<th>Status</th><th>Path</th><th class="sortable" id="sort">Diff, % &#x2195;</th><th>Pixels</th><th>Expected</th><th>Actual</th><th>Diff</th>
+</tr>
+</thead>
+<tbody>
+{{- range .Pairs}}
+<tr class="{{.Status}}" data-status="{{.Status}}" data-percent="{{.Percent}}" data-path="{{.Path}}"
+{{- with .Expected}} data-expected="{{.Full}}"{{end}}
+{{- with .Actual}} data-actual="{{.Full}}"{{end}}
+{{- with .Diffs}} data-diff="{{.Full}}"{{end}}>
+<td class="status">{{.Status}}</td>
+<td>{{.Path}}{{with .Err}}<br><small>{{.}}</small>{{end}}</td>
+<td>{{if .Result}}{{printf "%.2f" .Percent}}{{end}}</td>
+<td>{{with .Result}}{{.Diff}}{{end}}</td>
+<td class="thumb">{{with .Expected}}<img src="{{.Thumb}}" alt="expected">{{end}}</td>
+<td class="thumb">{{with .Actual}}<img src="{{.Thumb}}" alt="actual">{{end}}</td>
+<td class="thumb">{{with .Diffs}}<img src="{{.Thumb}}" alt="diff">{{end}}</td>
+</tr>
+{{- end}}
+</tbody>
+</table>
+<div id="viewer" class="hidden">
+<p>
+<button id="close">Close</button>
+<button data-mode="swipe">Swipe</button>
+<button data-mode="diff">Diff</button>
+<strong id="title"></strong>
+</p>
+<input id="slider" type="range" min="0" max="100" value="50">
+<br>
+<div id="stage">
+<img id="expected" alt="expected">
+<img id="actual" alt="actual">
+<div id="handle"></div>
+</div>
+</div>
+<script>
+(function () {
+  var rows = Array.prototype.slice.call(document.querySelectorAll("tbody tr"));
+  var filters = document.querySelectorAll(".filters input");
+  function filter() {
+    var shown = {};
+    filters.forEach(function (f) { shown[f.value] = f.checked; });
+    rows.forEach(function (r) { r.classList.toggle("hidden", !shown[r.dataset.status]); });
+  }
+  filters.forEach(function (f) { f.addEventListener("change", filter); });
+
+  var desc = true;
+  document.getElementById("sort").addEventListener("click", function () {
+    var body = document.querySelector("tbody");
+    rows.sort(function (a, b) {
+      var d = parseFloat(a.dataset.percent) - parseFloat(b.dataset.percent);
+      return desc ? -d : d;
+    });
+    rows.forEach(function (r) { body.appendChild(r); });
+    desc = !desc;
+  });
+
+  var viewer = document.getElementById("viewer");
+  var stage = document.getElementById("stage");
+  var expected = document.getElementById("expected");
+  var actual = document.getElementById("actual");
+  var handle = document.getElementById("handle");
+  var slider = document.getElementById("slider");
+  var row = null;
+  function swipe(pct) {
+    slider.value = pct;
+    actual.style.clipPath = "inset(0 0 0 " + pct + "%)";
+    handle.style.left = pct + "%";
+  }
+  function mode(m) {
+    var diff = m === "diff" && row.dataset.diff;
+    var overlay = !diff && row.dataset.expected && row.dataset.actual;
+    expected.src = diff || row.dataset.expected || row.dataset.actual || "";
+    actual.src = row.dataset.actual || "";
+    [actual, handle, slider].forEach(function (el) {
+      el.classList.toggle("hidden", !overlay);
+    });
+  }
+  rows.forEach(function (r) {
+    r.addEventListener("click", function () {
+      row = r;
+      document.getElementById("title").textContent = r.dataset.path;
+      viewer.classList.remove("hidden");
+      mode("swipe");
+      swipe(50);
+    });
+  });
+  document.querySelectorAll("[data-mode]").forEach(function (b) {
+    b.addEventListener("click", function () { mode(b.dataset.mode); });
+  });
+  slider.addEventListener("input", function () { swipe(slider.value); });
+  stage.addEventListener("pointermove", function (e) {
+    if (e.pointerType === "mouse" || e.buttons) {
+      var rect = stage.getBoundingClientRect();
+      swipe(Math.max(0, Math.min(100, (e.clientX - rect.left) / rect.width * 100)));
+    }
+  });
+  function close() { viewer.classList.add("hidden"); }
+  document.getElementById("close").addEventListener("click", close);
+  document.addEventListener("keydown", function (e) {
+    if (e.key === "Escape") { close(); }
+  });
+})();
+</script>
+</body>
+</html>
+`))
@@ -0,0 +1,66 @@
+package pixmatch
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHTMLReport(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"expected/a.png": "form-a.png",
+		"actual/a.png":   "form-b.png",
+		"expected/b.png": "gray8-a.png",
+		"actual/b.png":   "gray8-a.png",
+		"expected/c.png": "gray8-a.png",
+	}
+	for dst, src := range files {
+		copyFile(t, filepath.Join("samples", src), filepath.Join(dir, dst))
+	}
+	pairs, err := NewBatch(filepath.Join(dir, "expected"),
+		filepath.Join(dir, "actual")).
+		SetOutput(filepath.Join(dir, "output")).
+		Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pairs = append(pairs, &PairResult{Path: "<d>.png", Status: PairExtra,
+		Actual: filepath.Join(dir, "nonexists.png")})
+
+	var buf bytes.Buffer
+	err = NewHTMLReport().SetTitle("Visual <tests>").SetThumbSize(32).
+		Write(&buf, pairs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	html := buf.String()
+	tests := []struct {
+		substr string
+		count  int
+	}{
+		{"<tr class=", 4},
+		{`data-status="failed"`, 1},
+		{`data-status="passed"`, 1},
+		{`data-status="missing"`, 1},
+		{`data-status="extra"`, 1},
+		{"failed (1)", 1},
+		{"error (0)", 1},
+		// Thumbnails of the different pair with its diff, the same pair and
+		// the missing one.
+		{`<img src="data:image/png;base64,`, 6},
+		{`data-diff="data:image/png;base64,`, 1},
+		{"Visual &lt;tests&gt;", 2},
+		{"&lt;d&gt;.png", 2},
+	}
+	for _, test := range tests {
+		if n := strings.Count(html, test.substr); n != test.count {
+			t.Errorf("Expected %v got %v: %v", test.count, n, test.substr)
+		}
+	}
+	if strings.Contains(html, "<d>") {
+		t.Error("Expected escaped path got <d>")
+	}
+}
@@ -0,0 +1,215 @@
+package pixmatch
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// ResampleFilter is the filter to resample images.
+type ResampleFilter int
+
+const (
+	// ResampleNone does not resample images.
+	ResampleNone ResampleFilter = iota
+
+	// ResampleNearest takes the nearest pixel. It is the fastest filter,
+	// good for integer scales of pixel art.
+	ResampleNearest
+
+	// ResampleBilinear interpolates linearly between the nearest pixels.
+	ResampleBilinear
+
+	// ResampleLanczos uses the Lanczos kernel with 3 lobes. It is the
+	// slowest filter with the sharpest result.
+	ResampleLanczos
+)
+
+// kernel returns the kernel function of the filter and its support.
+func (f ResampleFilter) kernel() (func(float64) float64, float64) {
+	switch f {
+	case ResampleBilinear:
+		return func(x float64) float64 {
+			return math.Max(0, 1-math.Abs(x))
+		}, 1
+	case ResampleLanczos:
+		return func(x float64) float64 {
+			if x == 0 {
+				return 1
+			}
+			if math.Abs(x) >= 3 {
+				return 0
+			}
+			px := math.Pi * x
+			return 3 * math.Sin(px) * math.Sin(px/3) / (px * px)
+		}, 3
+	}
+	return nil, 0
+}
+
+// weights are the contributions of the source pixels to one destination
+// pixel.
+type weights struct {
+	first  int
+	values []float64
+}
+
+// resampleWeights calculates weights of source pixels for every destination
+// pixel. The kernel is stretched when downscaling to avoid aliasing.
+func resampleWeights(src, dst int, f ResampleFilter) []weights {
+	kernel, support := f.kernel()
+	scale := float64(src) / float64(dst)
+	stretch := math.Max(scale, 1)
+	support *= stretch
+	res := make([]weights, dst)
+	for i := range res {
+		center := (float64(i)+0.5)*scale - 0.5
+		first := int(math.Ceil(center - support))
+		last := int(math.Floor(center + support))
+		values := make([]float64, 0, last-first+1)
+		sum := 0.0
+		for j := first; j <= last; j++ {
+			w := kernel((float64(j) - center) / stretch)
+			values = append(values, w)
+			sum += w
+		}
+		for j := range values {
+			values[j] /= sum
+		}
+		res[i] = weights{first, values}
+	}
+	return res
+}
+
+// Resize resamples the image to the given dimensions with the filter. The
+// image is returned as is if the filter is ResampleNone or dimensions are
+// the same.
+func (img *Image) Resize(w, h int, f ResampleFilter) *Image {
+	b := img.Bounds()
+	if f == ResampleNone || b.Dx() == w && b.Dy() == h {
+		return img
+	}
+	if w <= 0 || h <= 0 || img.Empty() {
+		return NewImage(0, 0, img.Format)
+	}
+
+	// Colors are premultiplied to avoid dark halos around transparent
+	// pixels.
+	src := make([]float64, b.Dx()*b.Dy()*channels)
+	i := 0
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r, g, b, a := img.At(x, y).RGBA()
+			src[i], src[i+1], src[i+2], src[i+3] =
+				float64(r), float64(g), float64(b), float64(a)
+			i += channels
+		}
+	}
+
+	var dst []float64
+	if f == ResampleNearest {
+		dst = resampleNearest(src, b.Dx(), b.Dy(), w, h)
+	} else {
+		tmp := resampleRows(src, b.Dx(), b.Dy(), w, f)
+		dst = resampleColumns(tmp, w, b.Dy(), h, f)
+	}
+
+	// Premultiplied channels never exceed alpha.
+	clamp := func(v, max float64) float64 {
+		return math.Max(0, math.Min(max, math.Round(v)))
+	}
+	var m image.Image
+	if img.BPC == 8 {
+		m64 := image.NewRGBA64(image.Rect(0, 0, w, h))
+		for i := 0; i < len(dst); i += channels {
+			a := clamp(dst[i+3], 0xffff)
+			m64.SetRGBA64(i/channels%w, i/channels/w, color.RGBA64{
+				uint16(clamp(dst[i], a)), uint16(clamp(dst[i+1], a)),
+				uint16(clamp(dst[i+2], a)), uint16(a),
+			})
+		}
+		m = m64
+	} else {
+		m8 := image.NewRGBA(image.Rect(0, 0, w, h))
+		for i := 0; i < len(dst); i += channels {
+			a := clamp(dst[i+3]/0x101, 0xff)
+			m8.SetRGBA(i/channels%w, i/channels/w, color.RGBA{
+				uint8(clamp(dst[i]/0x101, a)), uint8(clamp(dst[i+1]/0x101, a)),
+				uint8(clamp(dst[i+2]/0x101, a)), uint8(a),
+			})
+		}
+		m = m8
+	}
+	return NewImageFromImage(m, img.Format)
+}
+
+// resampleNearest resamples channels of the image with the nearest pixels.
+func resampleNearest(src []float64, sw, sh, dw, dh int) []float64 {
+	dst := make([]float64, dw*dh*channels)
+	for y := 0; y < dh; y++ {
+		sy := intMin((2*y+1)*sh/(2*dh), sh-1)
+		for x := 0; x < dw; x++ {
+			sx := intMin((2*x+1)*sw/(2*dw), sw-1)
+			i := (sy*sw + sx) * channels
+			copy(dst[(y*dw+x)*channels:], src[i:i+channels])
+		}
+	}
+	return dst
+}
+
+// resampleRows resamples every row of the image to the new width.
+func resampleRows(src []float64, sw, sh, dw int, f ResampleFilter) []float64 {
+	ws := resampleWeights(sw, dw, f)
+	dst := make([]float64, dw*sh*channels)
+	for y := 0; y < sh; y++ {
+		row := src[y*sw*channels : (y+1)*sw*channels]
+		for x, w := range ws {
+			out := dst[(y*dw+x)*channels : (y*dw+x+1)*channels]
+			for k, v := range w.values {
+				sx := intMin(intMax(w.first+k, 0), sw-1) * channels
+				for c := 0; c < channels; c++ {
+					out[c] += v * row[sx+c]
+				}
+			}
+		}
+	}
+	return dst
+}
+
+// resampleColumns resamples every column of the image to the new height.
+func resampleColumns(src []float64, sw, sh, dh int, f ResampleFilter) []float64 {
+	ws := resampleWeights(sh, dh, f)
+	dst := make([]float64, sw*dh*channels)
+	for y, w := range ws {
+		out := dst[y*sw*channels : (y+1)*sw*channels]
+		for k, v := range w.values {
+			sy := intMin(intMax(w.first+k, 0), sh-1)
+			row := src[sy*sw*channels : (sy+1)*sw*channels]
+			for i := range out {
+				out[i] += v * row[i]
+			}
+		}
+	}
+	return dst
+}
+
+// Downscale resamples both images to the common size for the quick coarse
+// comparison. The common size keeps the aspect ratio of the smaller image,
+// and its larger side is not longer than size. Images are never upscaled,
+// the smaller image dimensions are used if size is not positive.
+func (img *Image) Downscale(img2 *Image, size int,
+	f ResampleFilter) (*Image, *Image) {
+	small := img.Bounds().Size()
+	if img2.Size() < img.Size() {
+		small = img2.Bounds().Size()
+	}
+	w, h := small.X, small.Y
+	if size > 0 && intMax(w, h) > size {
+		if w >= h {
+			w, h = size, intMax(h*size/w, 1)
+		} else {
+			w, h = intMax(w*size/h, 1), size
+		}
+	}
+	return img.Resize(w, h, f), img2.Resize(w, h, f)
+}
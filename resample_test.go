@@ -0,0 +1,105 @@
+package pixmatch
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+// renderScene draws the test scene at the scale of the device pixel ratio.
+func renderScene(scale int) *Image {
+	m := image.NewNRGBA(image.Rect(0, 0, 40*scale, 30*scale))
+	draw.Draw(m, m.Bounds(), &image.Uniform{color.White}, image.Point{},
+		draw.Src)
+	for _, r := range []image.Rectangle{image.Rect(5, 5, 25, 15),
+		image.Rect(10, 20, 35, 25)} {
+		r = image.Rect(r.Min.X*scale, r.Min.Y*scale, r.Max.X*scale,
+			r.Max.Y*scale)
+		draw.Draw(m, r, &image.Uniform{color.NRGBA{0x20, 0x40, 0xc0, 0xff}},
+			image.Point{}, draw.Src)
+	}
+	return NewImageFromImage(m, DefaultFormat)
+}
+
+func TestResize(t *testing.T) {
+	img := NewImageFromImage(testPattern(30, 20), DefaultFormat)
+	filters := []ResampleFilter{ResampleNearest, ResampleBilinear,
+		ResampleLanczos}
+	for _, f := range filters {
+		// The same size is not changed.
+		if got := img.Resize(30, 20, f); got != img {
+			t.Errorf("Expected %v got %v", img, got)
+		}
+		got := img.Resize(45, 10, f)
+		if got.Bounds() != image.Rect(0, 0, 45, 10) {
+			t.Errorf("Expected %v got %v", image.Rect(0, 0, 45, 10),
+				got.Bounds())
+		}
+
+		// Uniform color stays the same.
+		white := newUniformImage(7, 9, color.White).Resize(20, 3, f)
+		if !sameColors(white, newUniformImage(20, 3, color.White)) {
+			t.Errorf("Filter %v: expected uniform image", f)
+		}
+	}
+	if got := img.Resize(45, 10, ResampleNone); got != img {
+		t.Errorf("Expected %v got %v", img, got)
+	}
+
+	// 16-bit images stay 16-bit.
+	m := image.NewGray16(image.Rect(0, 0, 4, 4))
+	img16 := NewImageFromImage(m, DefaultFormat).Resize(8, 8, ResampleLanczos)
+	if _, ok := img16.Image.(*image.NRGBA64); !ok {
+		t.Errorf("Expected %T got %T", &image.NRGBA64{}, img16.Image)
+	}
+}
+
+func TestCompare_Resample(t *testing.T) {
+	img1, img2, img3 := renderScene(1), renderScene(2), renderScene(3)
+	if _, err := img1.Compare(img2, nil); err != ErrDimensionsDoNotMatch {
+		t.Errorf("Expected %v got %v", ErrDimensionsDoNotMatch, err)
+	}
+
+	tests := []struct {
+		img    *Image
+		filter ResampleFilter
+	}{
+		{img2, ResampleNearest},
+		{img3, ResampleNearest},
+		{img2, ResampleBilinear},
+		{img3, ResampleLanczos},
+	}
+	for _, tt := range tests {
+		opts := NewOptions().SetResample(tt.filter)
+		diff, err := img1.Compare(tt.img, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff != 0 {
+			t.Errorf("Filter %v: expected %v got %v", tt.filter, 0, diff)
+		}
+	}
+}
+
+func TestDownscale(t *testing.T) {
+	img1, img2 := renderScene(2), renderScene(3)
+	a, b := img1.Downscale(img2, 20, ResampleBilinear)
+	want := image.Rect(0, 0, 20, 15)
+	if a.Bounds() != want || b.Bounds() != want {
+		t.Errorf("Expected %v got %v and %v", want, a.Bounds(), b.Bounds())
+	}
+	diff, err := a.Compare(b, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff > 0 {
+		t.Errorf("Expected %v got %v", 0, diff)
+	}
+
+	// Images are not upscaled.
+	a, b = img1.Downscale(img2, 0, ResampleNearest)
+	if a != img1 || b.Bounds() != img1.Bounds() {
+		t.Errorf("Expected %v got %v", img1.Bounds(), b.Bounds())
+	}
+}
@@ -0,0 +1,141 @@
+package pixmatch
+
+import "image"
+
+// Result is the detailed outcome of the comparison of two images.
+type Result struct {
+	// Diff is the number of different pixels.
+	Diff int
+
+	// AA is the number of anti-aliased pixels, which are not counted as
+	// differences. Always zero if IncludeAA option is set.
+	AA int
+
+	// Shifted is the number of different pixels matching the pixels nearby
+	// within ShiftRadius, which are not counted as differences.
+	Shifted int
+
+	// Total is the total number of compared pixels.
+	Total int
+
+	// Extra is the number of different pixels outside of the overlapping
+	// area of the images with different dimensions.
+	Extra int
+
+	// Ignored is the number of skipped pixels, that are inside ignored
+	// regions or outside of included regions.
+	Ignored int
+
+	// Bounds is the bounding box of all differences. It is empty if no
+	// differences are found.
+	Bounds image.Rectangle
+
+	// MaxDelta is the maximum absolute delta between two pixels measured with
+	// the metric.
+	MaxDelta float64
+
+	// MeanDelta is the mean absolute delta of all compared pixels.
+	MeanDelta float64
+
+	// Darker is the number of differences where the pixel of the second
+	// image is darker.
+	Darker int
+
+	// Lighter is the number of differences where the pixel of the second
+	// image is lighter.
+	//
+	// Darker and Lighter cover only the compared pixels. Extra pixels of
+	// SizeOverlap policy are not compared, so Darker+Lighter+Extra equals
+	// Diff. Extra pixels of SizePad policy are compared with PadColor and
+	// classified, so Darker+Lighter equals Diff.
+	Lighter int
+
+	// Clusters are connected regions of the different pixels, sorted from
+	// the largest to the smallest one. Clusters are found only if
+	// Connectivity option is set.
+	Clusters []Cluster
+
+	// Offset is the translation of the second image detected by the
+	// alignment. The pixel (x, y) of the first image is compared with the
+	// pixel (x+dx, y+dy) of the second one.
+	Offset image.Point
+
+	// Exceeded reports that the number of different pixels exceeds the
+	// budget set by MaxDiff or MaxDiffPercent options.
+	Exceeded bool
+
+	// Partial reports that the comparison stopped as soon as the budget was
+	// exceeded. Counts of the partial result cover only the compared rows,
+	// clusters are not found.
+	Partial bool
+
+	// sumDelta accumulates absolute deltas to calculate MeanDelta.
+	sumDelta float64
+}
+
+// Percent returns the percentage of different pixels in range [0, 100].
+func (res *Result) Percent() float64 {
+	if res.Total == 0 {
+		return 0
+	}
+	return float64(res.Diff) / float64(res.Total) * 100
+}
+
+// ClustersAtLeast returns clusters having at least n pixels.
+func (res *Result) ClustersAtLeast(n int) []Cluster {
+	clusters := []Cluster{}
+	for _, cl := range res.Clusters {
+		if cl.Pixels >= n {
+			clusters = append(clusters, cl)
+		}
+	}
+	return clusters
+}
+
+// add accounts the delta of the single compared pixel.
+func (res *Result) add(delta float64) {
+	abs := delta
+	if abs < 0 {
+		abs = -abs
+	}
+	if abs > res.MaxDelta {
+		res.MaxDelta = abs
+	}
+	res.sumDelta += abs
+}
+
+// addDiff accounts the different pixel at the given point.
+func (res *Result) addDiff(pt image.Point, delta float64) {
+	res.Diff++
+	if delta < 0 {
+		res.Darker++
+	} else {
+		res.Lighter++
+	}
+	res.Bounds = res.Bounds.Union(image.Rectangle{pt, pt.Add(image.Pt(1, 1))})
+}
+
+// addExtra accounts the pixel outside of the overlapping area of the images
+// as the difference.
+func (res *Result) addExtra(pt image.Point) {
+	res.Diff++
+	res.Extra++
+	res.Bounds = res.Bounds.Union(image.Rectangle{pt, pt.Add(image.Pt(1, 1))})
+}
+
+// merge merges the partial result into the res.
+func (res *Result) merge(r *Result) {
+	res.Diff += r.Diff
+	res.AA += r.AA
+	res.Shifted += r.Shifted
+	res.Total += r.Total
+	res.Ignored += r.Ignored
+	res.Extra += r.Extra
+	res.Darker += r.Darker
+	res.Lighter += r.Lighter
+	res.Bounds = res.Bounds.Union(r.Bounds)
+	res.sumDelta += r.sumDelta
+	if r.MaxDelta > res.MaxDelta {
+		res.MaxDelta = r.MaxDelta
+	}
+}
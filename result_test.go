@@ -0,0 +1,82 @@
+package pixmatch
+
+import (
+	"image"
+	"testing"
+)
+
+func TestResultPercent(t *testing.T) {
+	res := &Result{Diff: 25, Total: 200}
+	want := 12.5
+	if res.Percent() != want {
+		t.Errorf("Expected %v got %v", want, res.Percent())
+	}
+
+	res = &Result{}
+	want = 0
+	if res.Percent() != want {
+		t.Errorf("Expected %v got %v", want, res.Percent())
+	}
+}
+
+func TestResultMerge(t *testing.T) {
+	res := &Result{}
+	row := &Result{}
+	row.add(-10)
+	row.addDiff(image.Pt(3, 4), -10)
+	row.add(5)
+	row.addDiff(image.Pt(7, 4), 5)
+	res.merge(row)
+
+	if res.Diff != 2 || res.Darker != 1 || res.Lighter != 1 {
+		t.Errorf("Expected 2 (1/1) got %v (%v/%v)",
+			res.Diff, res.Darker, res.Lighter)
+	}
+	want := image.Rect(3, 4, 8, 5)
+	if !res.Bounds.Eq(want) {
+		t.Errorf("Expected %v got %v", want, res.Bounds)
+	}
+	if res.MaxDelta != 10 {
+		t.Errorf("Expected %v got %v", 10, res.MaxDelta)
+	}
+}
+
+func TestCompareResult(t *testing.T) {
+	img1, _ := NewImageFromPath("./samples/form-a.png")
+	img2, _ := NewImageFromPath("./samples/form-b.png")
+	res, err := img1.CompareResult(img2, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Diff != 2909 {
+		t.Errorf("Expected %v got %v", 2909, res.Diff)
+	}
+	if res.Total != img1.Size() {
+		t.Errorf("Expected %v got %v", img1.Size(), res.Total)
+	}
+	if res.Darker+res.Lighter != res.Diff {
+		t.Errorf("Expected %v got %v", res.Diff, res.Darker+res.Lighter)
+	}
+	if res.AA == 0 {
+		t.Error("Expected anti-aliased pixels")
+	}
+	if res.Bounds.Empty() || !res.Bounds.In(img1.Bounds()) {
+		t.Errorf("Invalid bounds %v", res.Bounds)
+	}
+	if res.MeanDelta <= 0 || res.MeanDelta > res.MaxDelta {
+		t.Errorf("Invalid mean delta %v (max %v)", res.MeanDelta,
+			res.MaxDelta)
+	}
+}
+
+func TestCompareResult_Identical(t *testing.T) {
+	img1, _ := NewImageFromPath("./samples/form-a.png")
+	img2, _ := NewImageFromPath("./samples/form-a.png")
+	res, err := img1.CompareResult(img2, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Diff != 0 || !res.Bounds.Empty() || res.Percent() != 0 {
+		t.Errorf("Images should be identical, got %+v", res)
+	}
+}
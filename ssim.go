@@ -0,0 +1,265 @@
+package pixmatch
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// Constants for the structural similarity (SSIM) index. Read more about SSIM
+// https://en.wikipedia.org/wiki/Structural_similarity
+const (
+	// ssimK1 and ssimK2 are stabilization constants for the dynamic range of
+	// the 8-bit luminance.
+	ssimK1 = 0.01
+	ssimK2 = 0.03
+	ssimL  = 0xff
+
+	// ssimRadius and ssimSigma are parameters of the gaussian window 11x11.
+	ssimRadius = 5
+	ssimSigma  = 1.5
+)
+
+// msssimWeights are the weights of the scales for the multi-scale SSIM.
+var msssimWeights = []float64{0.0448, 0.2856, 0.3001, 0.2363, 0.1333}
+
+// SSIMMap is the map of the structural similarity index of every pixel.
+// Values range [-1, 1], where 1 means that pixels are structurally the same.
+type SSIMMap struct {
+	// Rect is the rectangle of the map, the same as the images' bounds.
+	Rect image.Rectangle
+
+	// Values of SSIM row by row.
+	Values []float64
+}
+
+// At returns the SSIM value of the pixel.
+func (m *SSIMMap) At(x, y int) float64 {
+	p := image.Pt(x, y)
+	if !p.In(m.Rect) {
+		return 0
+	}
+	p = p.Sub(m.Rect.Min)
+	return m.Values[p.Y*m.Rect.Dx()+p.X]
+}
+
+// Mean is the mean SSIM of all pixels, also known as global SSIM.
+func (m *SSIMMap) Mean() float64 {
+	return mean(m.Values)
+}
+
+// Image renders the map as the gray-scaled image, where white pixels are
+// structurally the same and black pixels are dissimilar.
+func (m *SSIMMap) Image() *Image {
+	gray := image.NewGray(m.Rect)
+	for y := m.Rect.Min.Y; y < m.Rect.Max.Y; y++ {
+		for x := m.Rect.Min.X; x < m.Rect.Max.X; x++ {
+			v := math.Max(0, m.At(x, y))
+			gray.SetGray(x, y, color.Gray{uint8(v*0xff + 0.5)})
+		}
+	}
+	return NewImageFromImage(gray, DefaultFormat)
+}
+
+// Colorize renders the map with the colormap. Structurally the same pixels
+// get the first color of the colormap, dissimilar pixels get the last one.
+func (m *SSIMMap) Colorize(cm Colormap) *Image {
+	rgba := image.NewRGBA(m.Rect)
+	for y := m.Rect.Min.Y; y < m.Rect.Max.Y; y++ {
+		for x := m.Rect.Min.X; x < m.Rect.Max.X; x++ {
+			rgba.SetRGBA(x, y, cm.At(1-m.At(x, y)))
+		}
+	}
+	return NewImageFromImage(rgba, DefaultFormat)
+}
+
+// SSIM returns the global structural similarity index between two images.
+// Only luminance is compared. Values range [-1, 1], where 1 means that
+// images are structurally the same.
+func (img *Image) SSIM(img2 *Image) (float64, error) {
+	m, err := img.SSIMMap(img2)
+	if err != nil {
+		return 0, err
+	}
+	return m.Mean(), nil
+}
+
+// SSIMMap returns the map of the structural similarity index of every pixel.
+func (img *Image) SSIMMap(img2 *Image) (*SSIMMap, error) {
+	if err := img.checkSSIM(img2); err != nil {
+		return nil, err
+	}
+	ssim, _ := ssimPlanes(newLumaPlane(img), newLumaPlane(img2))
+	return &SSIMMap{Rect: img.Bounds(), Values: ssim.pix}, nil
+}
+
+// MSSSIM returns the multi-scale structural similarity index between two
+// images. Images are downscaled up to 4 times, while they are larger than
+// the gaussian window.
+func (img *Image) MSSSIM(img2 *Image) (float64, error) {
+	if err := img.checkSSIM(img2); err != nil {
+		return 0, err
+	}
+	p1, p2 := newLumaPlane(img), newLumaPlane(img2)
+	weights := 0.0
+	values := make([]float64, 0, len(msssimWeights))
+	for i := range msssimWeights {
+		ssim, cs := ssimPlanes(p1, p2)
+		if i == len(msssimWeights)-1 || intMin(p1.w, p1.h)/2 <= 2*ssimRadius {
+			values = append(values, mean(ssim.pix))
+			weights += msssimWeights[i]
+			break
+		}
+		values = append(values, mean(cs.pix))
+		weights += msssimWeights[i]
+		p1, p2 = p1.downscale(), p2.downscale()
+	}
+
+	res := 1.0
+	for i, v := range values {
+		res *= math.Pow(math.Max(v, 0), msssimWeights[i]/weights)
+	}
+	return res, nil
+}
+
+// checkSSIM checks that images can be compared with SSIM.
+func (img *Image) checkSSIM(img2 *Image) error {
+	if img.Empty() || img2.Empty() {
+		return ErrImageIsEmpty
+	}
+	if !img.DimensionsEqual(img2) {
+		return ErrDimensionsDoNotMatch
+	}
+	return nil
+}
+
+// plane is the single channel image with float values.
+type plane struct {
+	w, h int
+	pix  []float64
+}
+
+// newPlane creates a new empty plane.
+func newPlane(w, h int) *plane {
+	return &plane{w, h, make([]float64, w*h)}
+}
+
+// newLumaPlane creates a plane of 8-bit luminance of the image. Transparent
+// pixels are blended with white color.
+func newLumaPlane(img *Image) *plane {
+	b := img.Bounds()
+	p := newPlane(b.Dx(), b.Dy())
+	i := 0
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r, g, b, a := img.At(x, y).RGBA()
+			bg := float64(0xffff - a)
+			p.pix[i] = (0.299*(float64(r)+bg) +
+				0.587*(float64(g)+bg) +
+				0.114*(float64(b)+bg)) / 0x101
+			i++
+		}
+	}
+	return p
+}
+
+// mul multiplies planes pixel by pixel.
+func (p *plane) mul(p2 *plane) *plane {
+	res := newPlane(p.w, p.h)
+	for i := range p.pix {
+		res.pix[i] = p.pix[i] * p2.pix[i]
+	}
+	return res
+}
+
+// downscale downscales the plane twice, averaging every 2x2 block.
+func (p *plane) downscale() *plane {
+	res := newPlane(p.w/2, p.h/2)
+	for y := 0; y < res.h; y++ {
+		for x := 0; x < res.w; x++ {
+			i := 2*y*p.w + 2*x
+			res.pix[y*res.w+x] = (p.pix[i] + p.pix[i+1] +
+				p.pix[i+p.w] + p.pix[i+p.w+1]) / 4
+		}
+	}
+	return res
+}
+
+// blur applies the gaussian blur with a separable kernel. Edge pixels are
+// replicated.
+func (p *plane) blur(kernel []float64) *plane {
+	r := len(kernel) / 2
+	tmp := newPlane(p.w, p.h)
+	for y := 0; y < p.h; y++ {
+		for x := 0; x < p.w; x++ {
+			sum := 0.0
+			for k, v := range kernel {
+				xx := intMin(intMax(x+k-r, 0), p.w-1)
+				sum += v * p.pix[y*p.w+xx]
+			}
+			tmp.pix[y*p.w+x] = sum
+		}
+	}
+	res := newPlane(p.w, p.h)
+	for y := 0; y < p.h; y++ {
+		for x := 0; x < p.w; x++ {
+			sum := 0.0
+			for k, v := range kernel {
+				yy := intMin(intMax(y+k-r, 0), p.h-1)
+				sum += v * tmp.pix[yy*p.w+x]
+			}
+			res.pix[y*p.w+x] = sum
+		}
+	}
+	return res
+}
+
+// gaussianKernel creates a normalized 1D gaussian kernel.
+func gaussianKernel(radius int, sigma float64) []float64 {
+	kernel := make([]float64, 2*radius+1)
+	sum := 0.0
+	for i := range kernel {
+		d := float64(i - radius)
+		kernel[i] = math.Exp(-d * d / (2 * sigma * sigma))
+		sum += kernel[i]
+	}
+	for i := range kernel {
+		kernel[i] /= sum
+	}
+	return kernel
+}
+
+// ssimPlanes calculates SSIM and contrast-structure maps of two planes.
+func ssimPlanes(p1, p2 *plane) (*plane, *plane) {
+	c1 := (ssimK1 * ssimL) * (ssimK1 * ssimL)
+	c2 := (ssimK2 * ssimL) * (ssimK2 * ssimL)
+	kernel := gaussianKernel(ssimRadius, ssimSigma)
+
+	mu1, mu2 := p1.blur(kernel), p2.blur(kernel)
+	s11 := p1.mul(p1).blur(kernel)
+	s22 := p2.mul(p2).blur(kernel)
+	s12 := p1.mul(p2).blur(kernel)
+
+	ssim, cs := newPlane(p1.w, p1.h), newPlane(p1.w, p1.h)
+	for i := range ssim.pix {
+		m1, m2 := mu1.pix[i], mu2.pix[i]
+		v1 := s11.pix[i] - m1*m1
+		v2 := s22.pix[i] - m2*m2
+		cov := s12.pix[i] - m1*m2
+		cs.pix[i] = (2*cov + c2) / (v1 + v2 + c2)
+		ssim.pix[i] = (2*m1*m2 + c1) / (m1*m1 + m2*m2 + c1) * cs.pix[i]
+	}
+	return ssim, cs
+}
+
+// mean is the arithmetic mean of the values.
+func mean(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
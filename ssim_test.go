@@ -0,0 +1,92 @@
+package pixmatch
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSSIM(t *testing.T) {
+	img1, _ := NewImageFromPath("./samples/form-a.png")
+	img2, _ := NewImageFromPath("./samples/form-b.png")
+	img3, _ := NewImageFromPath("./samples/form-a.png")
+
+	same, err := img1.SSIM(img3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(same-1) > 1e-9 {
+		t.Errorf("Expected %v got %v", 1, same)
+	}
+
+	diff, err := img1.SSIM(img2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff >= same || diff <= 0 {
+		t.Errorf("Expected value in range (0, 1) got %v", diff)
+	}
+}
+
+func TestSSIMMap(t *testing.T) {
+	img1, _ := NewImageFromPath("./samples/gray8-a.png")
+	img2, _ := NewImageFromPath("./samples/gray8-b.png")
+	m, err := img1.SSIMMap(img2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Values) != img1.Size() || !m.Rect.Eq(img1.Bounds()) {
+		t.Errorf("Invalid map size %v", m.Rect)
+	}
+	out := m.Image()
+	if !out.Bounds().Eq(img1.Bounds()) {
+		t.Errorf("Expected %v got %v", img1.Bounds(), out.Bounds())
+	}
+	if m.At(-1, -1) != 0 {
+		t.Errorf("Expected %v got %v", 0, m.At(-1, -1))
+	}
+}
+
+func TestMSSSIM(t *testing.T) {
+	img1, _ := NewImageFromPath("./samples/original/4a.png")
+	img2, _ := NewImageFromPath("./samples/original/4b.png")
+	same, err := img1.MSSSIM(img1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(same-1) > 1e-9 {
+		t.Errorf("Expected %v got %v", 1, same)
+	}
+	diff, err := img1.MSSSIM(img2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff >= same || diff <= 0 {
+		t.Errorf("Expected value in range (0, 1) got %v", diff)
+	}
+}
+
+func TestSSIM_Errors(t *testing.T) {
+	img1, _ := NewImageFromPath("./samples/bird-a.jpg")
+	img2, _ := NewImageFromPath("./samples/bird-c-small.jpg")
+	if _, err := img1.SSIM(img2); err != ErrDimensionsDoNotMatch {
+		t.Errorf("Expected %v got %v", ErrDimensionsDoNotMatch, err)
+	}
+	empty := NewImage(0, 0, DefaultFormat)
+	if _, err := img1.MSSSIM(empty); err != ErrImageIsEmpty {
+		t.Errorf("Expected %v got %v", ErrImageIsEmpty, err)
+	}
+}
+
+func TestGaussianKernel(t *testing.T) {
+	kernel := gaussianKernel(ssimRadius, ssimSigma)
+	if len(kernel) != 2*ssimRadius+1 {
+		t.Errorf("Expected %v got %v", 2*ssimRadius+1, len(kernel))
+	}
+	sum := 0.0
+	for _, v := range kernel {
+		sum += v
+	}
+	if math.Abs(sum-1) > 1e-9 {
+		t.Errorf("Expected %v got %v", 1, sum)
+	}
+}
@@ -0,0 +1,306 @@
+package pixmatch
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"math"
+)
+
+// rowWindow keeps the few decoded rows of the streamed image, enough to
+// compare the row in the middle and detect anti-aliased pixels.
+type rowWindow struct {
+	src *pngReader
+	img *Image
+	pix []byte
+	bpp int
+
+	// Rows [min, next) of the image are in the window.
+	min  int
+	next int
+}
+
+// newRowWindow allocates the window for the number of rows of the PNG
+// reader.
+func newRowWindow(src *pngReader, rows int) *rowWindow {
+	w := &rowWindow{src: src, bpp: 4}
+	w.img = &Image{
+		Image:   &image.NRGBA{},
+		Format:  FormatPNG,
+		PixData: make([]uint32, 0, rows*src.width*channels),
+		BPC:     4,
+	}
+	if src.wide() {
+		w.img.Image, w.img.BPC, w.bpp = &image.NRGBA64{}, 8, 8
+	}
+	w.pix = make([]byte, 0, rows*src.rowLen())
+	return w
+}
+
+// advance moves the window to contain rows [y1, y2) of the image.
+func (w *rowWindow) advance(y1, y2 int) error {
+	if y1 > w.min {
+		n := (y1 - w.min) * w.src.width
+		w.pix = w.pix[:copy(w.pix, w.pix[n*w.bpp:])]
+		w.img.PixData = w.img.PixData[:copy(w.img.PixData,
+			w.img.PixData[n*channels:])]
+		w.min = y1
+	}
+	for ; w.next < y2; w.next++ {
+		n := len(w.pix)
+		w.pix = w.pix[:n+w.src.rowLen()]
+		row := w.pix[n:]
+		if err := w.src.readRow(row); err != nil {
+			return err
+		}
+		if w.bpp == 8 {
+			for i := 0; i < len(row); i += 2 {
+				w.img.PixData = append(w.img.PixData,
+					uint32(row[i])<<8|uint32(row[i+1]))
+			}
+		} else {
+			for _, b := range row {
+				w.img.PixData = append(w.img.PixData, uint32(b))
+			}
+		}
+	}
+
+	r := image.Rect(0, w.min, w.src.width, w.next)
+	switch m := w.img.Image.(type) {
+	case *image.NRGBA:
+		m.Pix, m.Stride, m.Rect = w.pix, w.src.rowLen(), r
+	case *image.NRGBA64:
+		m.Pix, m.Stride, m.Rect = w.pix, w.src.rowLen(), r
+	}
+	return nil
+}
+
+// unsupportedStreamOption returns the name of the option, which cannot be
+// applied by the stream comparison, or an empty string.
+func unsupportedStreamOption(opts *Options) string {
+	switch {
+	case opts.AlignRadius > 0:
+		return "AlignRadius"
+	case opts.Resample != ResampleNone:
+		return "Resample"
+	case opts.Connectivity != 0:
+		return "Connectivity"
+	case opts.ClusterColor != nil:
+		return "ClusterColor"
+	case opts.Composite != LayoutNone:
+		return "Composite"
+	case opts.Legend:
+		return "Legend"
+	case opts.SizePolicy != SizeStrict:
+		return "SizePolicy"
+	case opts.Anchor != AnchorTopLeft:
+		return "Anchor"
+	}
+	return ""
+}
+
+// CompareStream compares two PNG images read from the readers row by row,
+// keeping only a few rows of every image in memory. The diff output is
+// written to opts.Output incrementally as PNG image.
+//
+// Interlaced PNG images are not supported. Options, that require the entire
+// image or change the pixels being compared, cannot be applied, so
+// ErrUnsupportedOption is returned if AlignRadius, Resample, Connectivity,
+// ClusterColor, Composite, Legend, SizePolicy or Anchor differ from the
+// defaults. The budget of differences stops the comparison only if there is
+// no output.
+func CompareStream(r1, r2 io.Reader, opts *Options) (*Result, error) {
+	return CompareStreamContext(context.Background(), r1, r2, opts)
+}
+
+// CompareStreamContext is like [CompareStream], but stops when the context
+// is done and returns the error of the context.
+func CompareStreamContext(ctx context.Context, r1, r2 io.Reader,
+	opts *Options) (*Result, error) {
+	if opts == nil {
+		opts = NewOptions()
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if name := unsupportedStreamOption(opts); name != "" {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedOption, name)
+	}
+
+	src1, err := newPNGReader(r1)
+	if err != nil {
+		return nil, err
+	}
+	defer src1.Close()
+	src2, err := newPNGReader(r2)
+	if err != nil {
+		return nil, err
+	}
+	defer src2.Close()
+
+	if src1.width == 0 || src1.height == 0 ||
+		src2.width == 0 || src2.height == 0 {
+		return nil, ErrImageIsEmpty
+	}
+	if src1.width != src2.width || src1.height != src2.height {
+		return nil, ErrDimensionsDoNotMatch
+	}
+	width, height := src1.width, src1.height
+	bounds := image.Rect(0, 0, width, height)
+
+	metric := opts.Metric
+	if metric == nil {
+		metric = YIQ{}
+	}
+	maxDelta := metric.Limit(opts.Threshold)
+
+	// Rows around the compared one are kept in memory. Anti-aliasing
+	// detection looks at neighbors of the neighbors.
+	radius := intMax(2*intMax(opts.AARadius, 1), opts.ShiftRadius)
+	win1 := newRowWindow(src1, 2*radius+1)
+	win2 := newRowWindow(src2, 2*radius+1)
+	deltaOf := win1.img.MetricDelta
+	if opts.HighPrecision {
+		deltaOf = win1.img.preciseDelta
+	}
+
+	// 16-bit inputs produce 16-bit output in high precision mode.
+	wide := opts.HighPrecision && (src1.wide() || src2.wide())
+	var enc *pngWriter
+	var out []byte
+	if opts.Output != nil {
+		enc, err = newPNGWriter(opts.Output, width, height, wide)
+		if err != nil {
+			return nil, err
+		}
+		out = make([]byte, width*enc.bpp)
+	}
+	// Colors are stored the same way as in the output of [Image.Compare]:
+	// premultiplied with 8 or 16 bits and converted into non-premultiplied.
+	set := func(x int, c color.Color) {
+		r, g, b, a := c.RGBA()
+		if !wide {
+			r, g, b, a = r>>8*0x101, g>>8*0x101, b>>8*0x101, a>>8*0x101
+		}
+		if a != 0 && a != 0xffff {
+			r, g, b = r*0xffff/a, g*0xffff/a, b*0xffff/a
+		}
+		if wide {
+			for i, v := range [...]uint32{r, g, b, a} {
+				binary.BigEndian.PutUint16(out[x*8+i*2:], uint16(v))
+			}
+			return
+		}
+		out[x*4], out[x*4+1] = uint8(r>>8), uint8(g>>8)
+		out[x*4+2], out[x*4+3] = uint8(b>>8), uint8(a>>8)
+	}
+
+	skipping := len(opts.Ignore) > 0 || len(opts.Include) > 0
+	budget := -1
+	if opts.hasBudget() {
+		total := width * height
+		if skipping {
+			total -= (&Image{Image: bounds}).countSkipped(opts)
+		}
+		budget = opts.budget(total)
+	}
+
+	res := &Result{}
+	for y := 0; y < height; y++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		y1 := intMax(y-radius, 0)
+		y2 := intMin(y+radius+1, height)
+		if err := win1.advance(y1, y2); err != nil {
+			return nil, err
+		}
+		if err := win2.advance(y1, y2); err != nil {
+			return nil, err
+		}
+		img, img2 := win1.img, win2.img
+		for i := range out {
+			out[i] = 0
+		}
+
+		for x := 0; x < width; x++ {
+			point := image.Pt(x, y)
+			if skipping && opts.Skipped(point) {
+				res.Ignored++
+				if enc != nil {
+					set(x, opts.IgnoreColor)
+				}
+				continue
+			}
+			pos := img.Position(point)
+			delta := deltaOf(img2, pos, pos, metric)
+			res.add(delta)
+
+			shifted := math.Abs(delta) > maxDelta && opts.ShiftRadius > 0 &&
+				img.nearMatch(img2, point, opts.ShiftRadius, metric,
+					maxDelta, opts.HighPrecision) &&
+				img2.nearMatch(img, point, opts.ShiftRadius, metric,
+					maxDelta, opts.HighPrecision)
+			if shifted {
+				res.Shifted++
+			}
+
+			if math.Abs(delta) > maxDelta && !shifted {
+				if !opts.IncludeAA &&
+					(img.detectAA(img2, point, opts) ||
+						img2.detectAA(img, point, opts)) {
+					res.AA++
+					if enc != nil && !opts.DiffMask {
+						set(x, opts.AAColor)
+					}
+				} else {
+					if enc != nil {
+						set(x, getDiffColor(opts, magnitude(metric, delta)))
+					}
+					res.addDiff(point, delta)
+				}
+			} else if enc != nil && !opts.DiffMask {
+				r, g, b, a := img.At(x, y).RGBA()
+				gray := NewColor(r, g, b, a).BlendToGray(opts.Alpha)
+				if wide {
+					gray = NewColor(r, g, b, a).blendToGray16(opts.Alpha)
+				}
+				if opts.Colormap != nil && opts.Faint && delta != 0 {
+					heat := opts.Colormap.At(math.Abs(magnitude(metric, delta)))
+					gray = mix(color.RGBAModel.Convert(gray).(color.RGBA),
+						heat, faintAlpha)
+				}
+				set(x, gray)
+			}
+		}
+		res.Total += width
+
+		if enc != nil {
+			if err := enc.writeRow(out); err != nil {
+				return nil, err
+			}
+		}
+		if opts.Progress != nil {
+			opts.Progress(y+1, height)
+		}
+		if enc == nil && budget >= 0 && res.Diff > budget {
+			res.Partial = y+1 < height
+			break
+		}
+	}
+	if enc != nil {
+		if err := enc.Close(); err != nil {
+			return nil, err
+		}
+	}
+
+	res.Exceeded = budget >= 0 && res.Diff > budget
+	res.Total -= res.Ignored
+	if res.Total > 0 {
+		res.MeanDelta = res.sumDelta / float64(res.Total)
+	}
+	return res, nil
+}
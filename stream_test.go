@@ -0,0 +1,135 @@
+package pixmatch
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image/color"
+	"image/png"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestCompareStream(t *testing.T) {
+	tests := []struct {
+		a, b string
+		opts *Options
+	}{
+		{"form-a.png", "form-b.png", NewOptions()},
+		{"form-a.png", "form-b.png", NewOptions().SetIncludeAA(true)},
+		{"form-a.png", "form-b.png", NewOptions().SetDiffMask(true)},
+		{"form-a.png", "form-b.png", NewOptions().
+			SetIgnore(NewRect(10, 10, 120, 40)).SetColormap(Viridis).SetFaint(true)},
+		{"form-a.png", "form-b.png", NewOptions().SetShiftRadius(3)},
+		{"form-a.png", "form-b.png", NewOptions().SetAADetector(AAGradient).
+			SetAARadius(2)},
+		{"gray8-a.png", "gray8-b.png", NewOptions()},
+		{"gray16-a.png", "gray16-b.png", NewOptions().SetHighPrecision(true)},
+		{"original/1a.png", "original/1b.png", NewOptions().SetThreshold(.05)},
+		{"original/4a.png", "original/4b.png", NewOptions().SetThreshold(.05)},
+		{"original/6a.png", "original/6b.png", NewOptions()},
+		{"models/rgb.png", "models/gray32.png", NewOptions()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.a, func(t *testing.T) {
+			imageA, _ := NewImageFromPath("./samples/" + tt.a)
+			imageB, _ := NewImageFromPath("./samples/" + tt.b)
+			var want bytes.Buffer
+			res1, err := imageA.CompareResult(imageB, tt.opts.SetOutput(&want))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			fp1, _ := os.Open("./samples/" + tt.a)
+			defer fp1.Close()
+			fp2, _ := os.Open("./samples/" + tt.b)
+			defer fp2.Close()
+			var got bytes.Buffer
+			res2, err := CompareStream(fp1, fp2, tt.opts.SetOutput(&got))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res1.Diff != res2.Diff || res1.AA != res2.AA ||
+				res1.Total != res2.Total || res1.Ignored != res2.Ignored ||
+				res1.Bounds != res2.Bounds {
+				t.Errorf("Expected %+v got %+v", res1, res2)
+			}
+
+			m1, err := png.Decode(&want)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m2, err := png.Decode(&got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !sameColors(m1, m2) {
+				t.Error("Expected the same output")
+			}
+		})
+	}
+}
+
+func TestCompareStream_Errors(t *testing.T) {
+	open := func(path string) *os.File {
+		fp, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { fp.Close() })
+		return fp
+	}
+
+	_, err := CompareStream(open("./samples/form-a.png"),
+		open("./samples/gray8-a.png"), nil)
+	if err != ErrDimensionsDoNotMatch {
+		t.Errorf("Expected %v got %v", ErrDimensionsDoNotMatch, err)
+	}
+
+	for name, opts := range map[string]*Options{
+		"AlignRadius":  NewOptions().SetAlignRadius(2),
+		"Resample":     NewOptions().SetResample(ResampleBilinear),
+		"Connectivity": NewOptions().SetConnectivity(8),
+		"ClusterColor": NewOptions().SetClusterColor(color.White),
+		"Composite":    NewOptions().SetComposite(LayoutHorizontal),
+		"Legend":       NewOptions().SetLegend(true),
+		"SizePolicy":   NewOptions().SetSizePolicy(SizePad),
+		"Anchor":       NewOptions().SetAnchor(AnchorCenter),
+	} {
+		_, err = CompareStream(open("./samples/form-a.png"),
+			open("./samples/form-b.png"), opts)
+		if !errors.Is(err, ErrUnsupportedOption) ||
+			!strings.HasSuffix(err.Error(), name) {
+			t.Errorf("%v: expected %v got %v", name, ErrUnsupportedOption, err)
+		}
+	}
+
+	_, err = CompareStream(open("./samples/form-a.png"),
+		open("./samples/bird-a.jpg"), nil)
+	if err != ErrUnsupportedFormat {
+		t.Errorf("Expected %v got %v", ErrUnsupportedFormat, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	opts := NewOptions().SetProgress(func(done, total int) {
+		if done == total/2 {
+			cancel()
+		}
+	})
+	_, err = CompareStreamContext(ctx, open("./samples/form-a.png"),
+		open("./samples/form-b.png"), opts)
+	if err != context.Canceled {
+		t.Errorf("Expected %v got %v", context.Canceled, err)
+	}
+
+	res, err := CompareStream(open("./samples/form-a.png"),
+		open("./samples/form-b.png"), NewOptions().SetMaxDiff(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Exceeded || !res.Partial {
+		t.Errorf("Expected %v got %v (%v partial)", true, res.Exceeded,
+			res.Partial)
+	}
+}